@@ -0,0 +1,297 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BatchOperation is a single query or mutation of a batched request.
+type BatchOperation struct {
+	op        operationType
+	v         interface{}
+	variables interface{}
+	options   []Option
+
+	// Err is the error of the operation, set by Client.Batch.
+	// It's non-nil if the query couldn't be constructed, the batch or the middleware failed,
+	// the response couldn't be decoded or the server returned GraphQL errors, as Errors.
+	Err error
+}
+
+// BatchQuery creates a query operation for Client.Batch,
+// with a query derived from q. q is populated with the response of the operation.
+func BatchQuery(q interface{}, variables interface{}, options ...Option) *BatchOperation {
+	return &BatchOperation{
+		op:        queryOperation,
+		v:         q,
+		variables: variables,
+		options:   options,
+	}
+}
+
+// BatchMutation creates a mutation operation for Client.Batch,
+// with a mutation derived from m. m is populated with the response of the operation.
+func BatchMutation(m interface{}, variables interface{}, options ...Option) *BatchOperation {
+	return &BatchOperation{
+		op:        mutationOperation,
+		v:         m,
+		variables: variables,
+		options:   options,
+	}
+}
+
+// Batch sends the operations as a JSON array in a single HTTP POST request,
+// the batching format that Hasura and Apollo Server accept,
+// and decodes each result into the struct of its operation.
+//
+// Failures of single operations don't fail the batch. They are reported in the Err field of each operation.
+// The returned error is non-nil only if a batched request failed, e.g. because of transport errors.
+// Operations with different request headers are sent in separate batched requests,
+// so that each operation is sent with its own headers.
+// Operations with Upload variables fail, because files can't be sent in batched requests.
+//
+// Each operation runs through the middleware of the client. The batched request is sent when the middleware
+// of every operation has either called the next handler or returned without calling it.
+func (c *Client) Batch(ctx context.Context, operations ...*BatchOperation) error {
+	var sent []*BatchOperation
+	var requests []*Request
+	var extensions []interface{}
+	for _, operation := range operations {
+		in, optionsOutput, err := c.construct(ctx, operation.op, operation.v, operation.variables, operation.options...)
+		if err == nil && len(findUploads(in.Variables)) > 0 {
+			// files are sent as multipart requests, which can't be batched
+			err = errors.New("file uploads can't be batched, use Mutate instead")
+		}
+		operation.Err = err
+		if err != nil {
+			continue
+		}
+		sent = append(sent, operation)
+		requests = append(requests, &Request{
+			OperationType: operation.op.String(),
+			OperationName: in.OperationName,
+			Query:         in.Query,
+			Variables:     in.Variables,
+			Header:        optionsOutput.headers,
+		})
+		extensions = append(extensions, optionsOutput.extensions)
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	g := &batchGatherer{client: c, ctx: ctx, reached: make([]bool, len(requests)), remaining: len(requests)}
+	var wg sync.WaitGroup
+	for i := range sent {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out, err := c.chain(func(ctx context.Context, req *Request) (*Response, error) {
+				return g.do(ctx, i, req)
+			})(ctx, requests[i])
+			g.finish(i)
+			operation := sent[i]
+			switch {
+			case err != nil:
+				operation.Err = err
+			case out == nil:
+				operation.Err = fmt.Errorf("empty response of %s operation", operation.op)
+			default:
+				operation.Err = c.decodeResponse(ctx, out, operation.v, extensions[i])
+			}
+		}(i)
+	}
+	wg.Wait()
+	return g.err
+}
+
+// batchGatherer collects the requests of batched operations from the innermost handlers
+// of their middleware chains, and sends them in a single batched request.
+type batchGatherer struct {
+	client *Client
+	ctx    context.Context
+
+	mu sync.Mutex
+	// reached reports whether each operation has reached the handler, or returned without calling it
+	reached   []bool
+	remaining int
+	pending   []*batchedRequest
+	sent      bool
+	// err is the error of the batched request
+	err error
+}
+
+type batchedRequest struct {
+	// index is the index of the operation in the batch
+	index int
+	req   *Request
+	done  chan coalescedResult
+}
+
+// do adds the request of operation i to the batch and waits for its response.
+// Requests of operations that call the handler again, e.g. to retry, are sent on their own.
+func (g *batchGatherer) do(ctx context.Context, i int, req *Request) (*Response, error) {
+	in := &requestPayload{
+		Query:         req.Query,
+		Variables:     req.Variables,
+		OperationName: req.OperationName,
+	}
+	g.mu.Lock()
+	if g.sent || g.reached[i] {
+		g.mu.Unlock()
+		return g.client.send(ctx, http.MethodPost, in, req.Header)
+	}
+	br := &batchedRequest{index: i, req: req, done: make(chan coalescedResult, 1)}
+	g.pending = append(g.pending, br)
+	batch := g.reach(i)
+	g.mu.Unlock()
+	if batch != nil {
+		g.flush(batch)
+	}
+
+	select {
+	case result := <-br.done:
+		return result.out, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// finish records that the middleware chain of operation i has returned.
+// If it didn't call the handler, the batch doesn't wait for the operation.
+func (g *batchGatherer) finish(i int) {
+	g.mu.Lock()
+	var batch []*batchedRequest
+	if !g.reached[i] {
+		batch = g.reach(i)
+	}
+	g.mu.Unlock()
+	if batch != nil {
+		g.flush(batch)
+	}
+}
+
+// reach marks operation i as reached, and takes the pending requests
+// if all operations are reached. The mutex must be held.
+func (g *batchGatherer) reach(i int) []*batchedRequest {
+	g.reached[i] = true
+	g.remaining--
+	if g.remaining > 0 || g.sent {
+		return nil
+	}
+	g.sent = true
+	return g.pending
+}
+
+// flush sends the requests in a batched request for each set of request headers,
+// and delivers the responses.
+func (g *batchGatherer) flush(batch []*batchedRequest) {
+	// the requests are sent in the order of the operations, rather than the order they reached the handler
+	sort.Slice(batch, func(i, j int) bool {
+		return batch[i].index < batch[j].index
+	})
+	var keys []string
+	groups := make(map[string][]*batchedRequest)
+	for _, br := range batch {
+		key := headerKey(br.req.Header)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], br)
+	}
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(group []*batchedRequest) {
+			defer wg.Done()
+			g.send(group)
+		}(groups[key])
+	}
+	wg.Wait()
+}
+
+// send sends the requests with the same request headers as a batched request and delivers the responses.
+func (g *batchGatherer) send(batch []*batchedRequest) {
+	payloads := make([]*requestPayload, len(batch))
+	for i, br := range batch {
+		payloads[i] = &requestPayload{
+			Query:         br.req.Query,
+			Variables:     br.req.Variables,
+			OperationName: br.req.OperationName,
+		}
+	}
+	out, err := g.client.sendBatch(g.ctx, payloads, batch[0].req.Header)
+	if err != nil {
+		g.mu.Lock()
+		if g.err == nil {
+			g.err = err
+		}
+		g.mu.Unlock()
+	}
+	for i, br := range batch {
+		if err != nil {
+			br.done <- coalescedResult{err: err}
+			continue
+		}
+		br.done <- coalescedResult{out: &out[i]}
+	}
+}
+
+// headerKey returns a key that is equal for equal request headers.
+func headerKey(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(http.CanonicalHeaderKey(key))
+		for _, value := range header[key] {
+			b.WriteString("\x00")
+			b.WriteString(value)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// sendBatch sends the payloads as a JSON array and decodes the array of responses.
+func (c *Client) sendBatch(ctx context.Context, payloads []*requestPayload, headers http.Header) ([]Response, error) {
+	req, err := c.newPostRequest(payloads)
+	if err != nil {
+		return nil, err
+	}
+	var out []Response
+	err = c.roundTrip(ctx, req, headers, &out)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != len(payloads) {
+		return nil, fmt.Errorf("invalid batch response: got %d results, want: %d", len(out), len(payloads))
+	}
+	return out, nil
+}
+
+// decodeResponse binds the extensions, unmarshals the data into v and returns the GraphQL errors of the response.
+func (c *Client) decodeResponse(ctx context.Context, r *Response, v interface{}, extensions interface{}) error {
+	err := r.bindExtensions(extensions)
+	if err != nil {
+		return err
+	}
+	if r.Data != nil {
+		err := c.decode(ctx, *r.Data, v)
+		if err != nil {
+			return err
+		}
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+func TestClient_Batch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in []struct {
+			Query     string
+			Variables map[string]interface{}
+		}
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(in), 3; got != want {
+			t.Fatalf("got %d operations, want: %d", got, want)
+		}
+		if got, want := in[0].Query, `query ($id:ID!){user(id: $id){name}}`; got != want {
+			t.Errorf("got query: %q, want: %q", got, want)
+		}
+		if got, want := in[2].Query, `mutation ($name:String!){insertUser(name: $name){id}}`; got != want {
+			t.Errorf("got query: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `[
+			{"data": {"user": {"name": "Gopher"}}},
+			{"data": {"user": null}, "errors": [{"message": "user not found", "path": ["user"]}]},
+			{"data": {"insertUser": {"id": "3"}}}
+		]`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	type userQuery struct {
+		User *struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	var q1, q2 userQuery
+	var m struct {
+		InsertUser struct {
+			ID graphql.ID
+		} `graphql:"insertUser(name: $name)"`
+	}
+	operations := []*graphql.BatchOperation{
+		graphql.BatchQuery(&q1, map[string]interface{}{"id": graphql.ID("1")}),
+		graphql.BatchQuery(&q2, map[string]interface{}{"id": graphql.ID("2")}),
+		graphql.BatchMutation(&m, map[string]interface{}{"name": graphql.String("Gopher")}),
+	}
+	err := client.Batch(context.Background(), operations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if operations[0].Err != nil {
+		t.Errorf("got operation 0 error: %v, want: nil", operations[0].Err)
+	}
+	if q1.User == nil || q1.User.Name != "Gopher" {
+		t.Errorf("got wrong q1.User: %+v", q1.User)
+	}
+	if got, want := operations[1].Err, "Message: user not found, Locations: []"; got == nil || got.Error() != want {
+		t.Errorf("got operation 1 error: %v, want: %v", got, want)
+	}
+	if q2.User != nil {
+		t.Errorf("got non-nil q2.User: %+v, want: nil", *q2.User)
+	}
+	if operations[2].Err != nil {
+		t.Errorf("got operation 2 error: %v, want: nil", operations[2].Err)
+	}
+	if got, want := m.InsertUser.ID, "3"; got != want {
+		t.Errorf("got m.InsertUser.ID: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Batch_invalidResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `[{"data": {"user": {"name": "Gopher"}}}]`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q1, q2 struct {
+		User struct {
+			Name string
+		}
+	}
+	err := client.Batch(context.Background(), graphql.BatchQuery(&q1, nil), graphql.BatchQuery(&q2, nil))
+	if got, want := err, "invalid batch response: got 1 results, want: 2"; got == nil || got.Error() != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Batch_upload(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in []json.RawMessage
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := len(in), 1; got != want {
+			t.Errorf("got %d operations, want: %d", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `[{"data": {"user": {"name": "Gopher"}}}]`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name string
+		}
+	}
+	var m struct {
+		UploadAvatar struct {
+			URL string
+		} `graphql:"uploadAvatar(file: $file)"`
+	}
+	operations := []*graphql.BatchOperation{
+		graphql.BatchQuery(&q, nil),
+		graphql.BatchMutation(&m, map[string]interface{}{
+			"file": graphql.Upload{File: strings.NewReader("avatar"), Name: "avatar.png"},
+		}),
+	}
+	err := client.Batch(context.Background(), operations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if operations[0].Err != nil {
+		t.Errorf("got operation 0 error: %v, want: nil", operations[0].Err)
+	}
+	if got, want := operations[1].Err, "file uploads can't be batched, use Mutate instead"; got == nil || got.Error() != want {
+		t.Errorf("got operation 1 error: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Batch_headers(t *testing.T) {
+	var mu sync.Mutex
+	roles := make(map[string][]string)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in []struct {
+			Variables map[string]interface{}
+		}
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		role := req.Header.Get("X-Hasura-Role")
+		results := make([]string, len(in))
+		mu.Lock()
+		for i, op := range in {
+			id := fmt.Sprint(op.Variables["id"])
+			roles[role] = append(roles[role], id)
+			results[i] = fmt.Sprintf(`{"data": {"user": {"name": %q}}}`, role+id)
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, "["+strings.Join(results, ",")+"]")
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	type userQuery struct {
+		User struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	var q1, q2, q3 userQuery
+	operations := []*graphql.BatchOperation{
+		graphql.BatchQuery(&q1, map[string]interface{}{"id": graphql.ID("1")}, graphql.RequestHeader("X-Hasura-Role", "user")),
+		graphql.BatchQuery(&q2, map[string]interface{}{"id": graphql.ID("2")}, graphql.RequestHeader("X-Hasura-Role", "admin")),
+		graphql.BatchQuery(&q3, map[string]interface{}{"id": graphql.ID("3")}, graphql.RequestHeader("X-Hasura-Role", "user")),
+	}
+	err := client.Batch(context.Background(), operations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, operation := range operations {
+		if operation.Err != nil {
+			t.Errorf("got operation %d error: %v, want: nil", i, operation.Err)
+		}
+	}
+
+	// the operations with conflicting headers are sent in separate batched requests
+	want := map[string][]string{"user": {"1", "3"}, "admin": {"2"}}
+	if !reflect.DeepEqual(roles, want) {
+		t.Errorf("got operations by role: %v, want: %v", roles, want)
+	}
+	for _, tc := range []struct {
+		got, want string
+	}{
+		{q1.User.Name, "user1"},
+		{q2.User.Name, "admin2"},
+		{q3.User.Name, "user3"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("got user name: %q, want: %q", tc.got, tc.want)
+		}
+	}
+}
+
+func TestClient_Query_batching(t *testing.T) {
+	var mu sync.Mutex
+	var batchSizes []int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in []struct {
+			Variables struct {
+				ID string
+			}
+		}
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Error(err)
+			return
+		}
+		mu.Lock()
+		batchSizes = append(batchSizes, len(in))
+		mu.Unlock()
+
+		out := make([]interface{}, len(in))
+		for i, op := range in {
+			out[i] = map[string]interface{}{
+				"data": map[string]interface{}{
+					"user": map[string]interface{}{"name": "user" + op.Variables.ID},
+				},
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(out); err != nil {
+			t.Error(err)
+		}
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithBatching(time.Minute, 4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			var q struct {
+				User struct {
+					Name string
+				} `graphql:"user(id: $id)"`
+			}
+			err := client.Query(context.Background(), &q, map[string]interface{}{"id": graphql.ID(id)})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got, want := q.User.Name, "user"+id; got != want {
+				t.Errorf("got q.User.Name: %q, want: %q", got, want)
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	if got, want := fmt.Sprint(batchSizes), "[4 4]"; got != want {
+		t.Errorf("got batch sizes: %s, want: %s", got, want)
+	}
+}
+
+func TestClient_Query_batchingPersistedQueries(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in struct {
+			Query      string
+			Extensions struct {
+				PersistedQuery struct {
+					Sha256Hash string
+				}
+			}
+		}
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		if in.Query != "" || in.Extensions.PersistedQuery.Sha256Hash == "" {
+			t.Errorf("got query: %q, hash: %q, want only the hash", in.Query, in.Extensions.PersistedQuery.Sha256Hash)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithAutomaticPersistedQueries(false).
+		WithBatching(time.Millisecond, 4)
+
+	// a query that isn't merged with others is sent with its hash
+	var q struct {
+		User struct {
+			Name string
+		}
+	}
+	err := client.Query(context.Background(), &q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, "Gopher"; got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package graphql
+
+import (
+	"testing"
+)
+
+type benchmarkQuery struct {
+	Repository struct {
+		Issue struct {
+			Author struct {
+				Login     String
+				AvatarURL URI `graphql:"avatarUrl(size: 72)"`
+			}
+			Body      String
+			Reactions struct {
+				TotalCount Int
+				Nodes      []struct {
+					Content String
+					User    struct {
+						Login String
+					}
+				}
+			} `graphql:"reactions(first: 10)"`
+		} `graphql:"issue(number: $issueNumber)"`
+	} `graphql:"repository(owner: $repositoryOwner, name: $repositoryName)"`
+}
+
+var benchmarkVariables = map[string]interface{}{
+	"repositoryOwner": String("shurcooL-test"),
+	"repositoryName":  String("test-repo"),
+	"issueNumber":     Int(1),
+}
+
+func BenchmarkConstructQuery(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := constructQuery(&benchmarkQuery{}, benchmarkVariables, OperationName("Issue"))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkConstructQuery_uncached constructs the query like BenchmarkConstructQuery, without the cache.
+func BenchmarkConstructQuery_uncached(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		arguments, err := queryArguments(benchmarkVariables, nil, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		q, err := query(&benchmarkQuery{})
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = "query Issue(" + arguments + ")" + q
+	}
+}
+
+func BenchmarkConstructQuery_orderedMap(b *testing.B) {
+	type query struct {
+		Repository [][2]interface{} `graphql:"repository(owner: $repositoryOwner, name: $repositoryName)"`
+	}
+	q := query{Repository: [][2]interface{}{
+		{"issue(number: $issueNumber)", benchmarkQuery{}.Repository.Issue},
+	}}
+	for i := 0; i < b.N; i++ {
+		_, err := constructQuery(q, benchmarkVariables, OperationName("Issue"))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// coalescer merges concurrent query requests, made within a time window
+// or up to a maximum batch size, into one batched HTTP request,
+// and demultiplexes the responses back to the callers.
+type coalescer struct {
+	client       *Client
+	window       time.Duration
+	maxBatchSize int
+
+	mu      sync.Mutex
+	pending []*coalescedRequest
+	timer   *time.Timer
+}
+
+type coalescedRequest struct {
+	ctx    context.Context
+	v      interface{}
+	method string
+	in     *requestPayload
+	done   chan coalescedResult
+}
+
+type coalescedResult struct {
+	out *Response
+	err error
+}
+
+// WithBatching enables automatic batching of query operations. Concurrent queries
+// made within window are merged into one batched HTTP request, see Batch.
+// A batch is sent earlier if it reaches maxBatchSize queries. If maxBatchSize <= 0,
+// the batch size is unlimited.
+//
+// Mutations and queries with request headers of their own are never batched.
+// With automatic persisted queries, a query that isn't merged with others is sent with its hash,
+// while batched requests carry the full query strings.
+func (c *Client) WithBatching(window time.Duration, maxBatchSize int) *Client {
+	c.coalescer = &coalescer{
+		client:       c,
+		window:       window,
+		maxBatchSize: maxBatchSize,
+	}
+	return c
+}
+
+// do adds the payload of the query derived from v to the pending batch and waits for its response.
+// method is used if the request isn't merged with others.
+func (co *coalescer) do(ctx context.Context, v interface{}, method string, in *requestPayload) (*Response, error) {
+	req := &coalescedRequest{
+		ctx:    ctx,
+		v:      v,
+		method: method,
+		in:     in,
+		done:   make(chan coalescedResult, 1),
+	}
+
+	co.mu.Lock()
+	co.pending = append(co.pending, req)
+	switch {
+	case co.maxBatchSize > 0 && len(co.pending) >= co.maxBatchSize:
+		batch := co.take()
+		co.mu.Unlock()
+		go co.flush(batch)
+	case len(co.pending) == 1:
+		co.timer = time.AfterFunc(co.window, co.flushPending)
+		co.mu.Unlock()
+	default:
+		co.mu.Unlock()
+	}
+
+	select {
+	case result := <-req.done:
+		return result.out, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// take removes the pending requests and stops the window timer.
+// The mutex must be held.
+func (co *coalescer) take() []*coalescedRequest {
+	batch := co.pending
+	co.pending = nil
+	if co.timer != nil {
+		co.timer.Stop()
+		co.timer = nil
+	}
+	return batch
+}
+
+func (co *coalescer) flushPending() {
+	co.mu.Lock()
+	batch := co.take()
+	co.mu.Unlock()
+	co.flush(batch)
+}
+
+// flush sends the batch and delivers the responses to the callers.
+// A single request is sent without batching.
+func (co *coalescer) flush(batch []*coalescedRequest) {
+	switch len(batch) {
+	case 0:
+		return
+	case 1:
+		req := batch[0]
+		out, err := co.client.executeSingle(req.ctx, queryOperation, req.v, req.in, req.method, nil)
+		req.done <- coalescedResult{out, err}
+		return
+	}
+
+	ctx, cancel := batchContext(batch)
+	defer cancel()
+
+	payloads := make([]*requestPayload, len(batch))
+	for i, req := range batch {
+		payloads[i] = req.in
+	}
+	out, err := co.client.sendBatch(ctx, payloads, nil)
+	for i, req := range batch {
+		if err != nil {
+			req.done <- coalescedResult{nil, err}
+			continue
+		}
+		req.done <- coalescedResult{&out[i], nil}
+	}
+}
+
+// batchContext returns a context that is canceled when the contexts of all requests in the batch are done.
+// It has the values of the context of the first request, so that the span of the batched request
+// is a child of the span of that caller.
+func batchContext(batch []*coalescedRequest) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(valuesContext{batch[0].ctx})
+	go func() {
+		for _, req := range batch {
+			select {
+			case <-req.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// valuesContext has the values of the embedded context, without its deadline and cancellation.
+type valuesContext struct {
+	context.Context
+}
+
+func (valuesContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (valuesContext) Done() <-chan struct{}       { return nil }
+func (valuesContext) Err() error                  { return nil }
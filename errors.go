@@ -0,0 +1,186 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hasura/go-graphql-client/internal/jsonutil"
+)
+
+// Common values of the "code" field in GraphQL error extensions.
+// Hasura reports the kebab-case codes, Apollo Server the upper snake case ones.
+const (
+	ErrorCodeValidationFailed        = "validation-failed"
+	ErrorCodeParseFailed             = "parse-failed"
+	ErrorCodeAccessDenied            = "access-denied"
+	ErrorCodePermissionError         = "permission-error"
+	ErrorCodeInvalidJWT              = "invalid-jwt"
+	ErrorCodeInvalidHeaders          = "invalid-headers"
+	ErrorCodeConstraintViolation     = "constraint-violation"
+	ErrorCodeDataException           = "data-exception"
+	ErrorCodeNotFound                = "not-found"
+	ErrorCodeUnexpected              = "unexpected"
+	ErrorCodeGraphQLValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	ErrorCodeGraphQLParseFailed      = "GRAPHQL_PARSE_FAILED"
+	ErrorCodeBadUserInput            = "BAD_USER_INPUT"
+	ErrorCodeUnauthenticated         = "UNAUTHENTICATED"
+	ErrorCodeForbidden               = "FORBIDDEN"
+	ErrorCodeInternalServerError     = "INTERNAL_SERVER_ERROR"
+)
+
+// HTTPError is returned when the GraphQL server responds with a non-200 OK status code.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+// Error implements error interface.
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("non-200 OK status code: %v body: %q", e.Status, e.Body)
+}
+
+// Errors represents the "errors" array in a response from a GraphQL server.
+// If returned via error interface, the slice is expected to contain at least 1 element.
+//
+// Specification: https://facebook.github.io/graphql/#sec-Errors.
+type Errors []Error
+
+// Error represents a single error in the "errors" array of a GraphQL response.
+type Error struct {
+	Message   string
+	Locations []Location
+	// Path is the path of the response field which experienced the error,
+	// with string elements for field names and number elements for list indices.
+	Path []interface{}
+	// Extensions is the raw "extensions" object of the error. Servers usually
+	// put a machine readable error class in its "code" field.
+	Extensions map[string]interface{}
+}
+
+// Location is a position of the error in the query document.
+type Location struct {
+	Line   int
+	Column int
+}
+
+// Error implements error interface.
+func (e Error) Error() string {
+	return fmt.Sprintf("Message: %s, Locations: %+v", e.Message, e.Locations)
+}
+
+// Code returns the "code" field of the error extensions, or an empty string if there isn't any.
+func (e Error) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// Error implements error interface.
+func (e Errors) Error() string {
+	b := strings.Builder{}
+	for _, err := range e {
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// As sets target to the first error if target is a *Error, so that errors.As
+// finds a single Error on every Go version. errors.As follows Unwrap() []error since Go 1.20 only.
+func (e Errors) As(target interface{}) bool {
+	t, ok := target.(*Error)
+	if !ok || len(e) == 0 {
+		return false
+	}
+	*t = e[0]
+	return true
+}
+
+// Unwrap returns the individual errors, so that errors.Is and errors.As
+// can find an error matching the individual errors since Go 1.20.
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// HasCode reports whether any of the errors has one of the extension codes.
+func (e Errors) HasCode(codes ...string) bool {
+	for _, err := range e {
+		code := err.Code()
+		if code == "" {
+			continue
+		}
+		for _, c := range codes {
+			if code == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasMessageOrCode reports whether any of the errors has the message, or the extension code.
+func (e Errors) hasMessageOrCode(message string, code string) bool {
+	for _, err := range e {
+		if err.Message == message || err.Code() == code {
+			return true
+		}
+	}
+	return false
+}
+
+// HasErrorCode reports whether err wraps GraphQL Errors with one of the extension codes.
+func HasErrorCode(err error, codes ...string) bool {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return false
+	}
+	return errs.HasCode(codes...)
+}
+
+// IsValidationFailed reports whether the GraphQL server rejected the query document as invalid.
+func IsValidationFailed(err error) bool {
+	return HasErrorCode(err, ErrorCodeValidationFailed, ErrorCodeParseFailed, ErrorCodeGraphQLValidationFailed, ErrorCodeGraphQLParseFailed)
+}
+
+// IsAccessDenied reports whether the GraphQL server rejected the request due to missing authentication or permissions.
+func IsAccessDenied(err error) bool {
+	return HasErrorCode(err, ErrorCodeAccessDenied, ErrorCodePermissionError, ErrorCodeInvalidJWT, ErrorCodeInvalidHeaders, ErrorCodeUnauthenticated, ErrorCodeForbidden)
+}
+
+// IsConstraintViolation reports whether a mutation violated a database constraint, such as a unique or foreign key.
+func IsConstraintViolation(err error) bool {
+	return HasErrorCode(err, ErrorCodeConstraintViolation)
+}
+
+// IsDataException reports whether the GraphQL server rejected invalid input values.
+func IsDataException(err error) bool {
+	return HasErrorCode(err, ErrorCodeDataException, ErrorCodeBadUserInput)
+}
+
+// FieldError is a GraphQL error resolved against the query data structure.
+type FieldError struct {
+	Error
+	// FieldPath is the path of the Go field that experienced the error,
+	// e.g. "Repository.Issues[1].Title". It's empty if the error isn't
+	// associated with a response field or the path doesn't exist in the query.
+	FieldPath string
+}
+
+// resolveFieldErrors maps the GraphQL errors to the fields of the query data structure v.
+func resolveFieldErrors(v interface{}, errs Errors) []FieldError {
+	fieldErrs := make([]FieldError, 0, len(errs))
+	for _, err := range errs {
+		fe := FieldError{Error: err}
+		if len(err.Path) > 0 {
+			if fieldPath, ok := jsonutil.FieldPath(v, err.Path); ok {
+				fe.FieldPath = fieldPath
+			}
+		}
+		fieldErrs = append(fieldErrs, fe)
+	}
+	return fieldErrs
+}
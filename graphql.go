@@ -7,18 +7,40 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"net/url"
+	"reflect"
 
 	"github.com/hasura/go-graphql-client/internal/jsonutil"
 	"golang.org/x/net/context/ctxhttp"
 )
 
+// RequestModifier allows modifying the http.Request before it's sent to the GraphQL server,
+// e.g. to set authentication or role headers.
+type RequestModifier func(r *http.Request)
+
 // Client is a GraphQL client.
 type Client struct {
-	url        string // GraphQL server URL.
-	httpClient *http.Client
+	url              string // GraphQL server URL.
+	httpClient       *http.Client
+	requestModifiers []RequestModifier
+	persistedQueries *persistedQueries
+	coalescer        *coalescer
+	retryPolicy      *RetryPolicy
+	middlewares      []Middleware
+	tracer           Tracer
+	// queryMethod is the HTTP method of query operations, POST by default
+	queryMethod  string
+	maxURLLength int
+	// typeNames are the GraphQL type names of Go types in variable declarations
+	typeNames map[reflect.Type]string
+	// types are the Go types of interface fields by __typename
+	types *typeRegistry
 }
 
+// DefaultMaxURLLength is the default maximum length of the URL of a GET request.
+// Longer requests are sent with POST.
+const DefaultMaxURLLength = 2048
+
 // NewClient creates a GraphQL client targeting the specified GraphQL server URL.
 // If httpClient is nil, then http.DefaultClient is used.
 func NewClient(url string, httpClient *http.Client) *Client {
@@ -26,50 +48,115 @@ func NewClient(url string, httpClient *http.Client) *Client {
 		httpClient = http.DefaultClient
 	}
 	return &Client{
-		url:        url,
-		httpClient: httpClient,
+		url:          url,
+		httpClient:   httpClient,
+		queryMethod:  http.MethodPost,
+		maxURLLength: DefaultMaxURLLength,
 	}
 }
 
+// WithRequestModifier appends request modifiers that are applied, in order,
+// to every HTTP request sent by the client.
+// Headers set by the RequestHeader option of a single call take precedence.
+func (c *Client) WithRequestModifier(modifiers ...RequestModifier) *Client {
+	c.requestModifiers = append(c.requestModifiers, modifiers...)
+	return c
+}
+
+// WithGETQueries sends query operations with HTTP GET, encoding the query, variables
+// and operation name into the URL query parameters, so they can be cached by CDNs and HTTP caches.
+// Mutations are always sent with POST. Queries are sent with POST too if the URL
+// would be longer than maxURLLength. If maxURLLength <= 0, DefaultMaxURLLength is used.
+// The RequestMethod option overrides the method for a single query.
+func (c *Client) WithGETQueries(maxURLLength int) *Client {
+	if maxURLLength <= 0 {
+		maxURLLength = DefaultMaxURLLength
+	}
+	c.queryMethod = http.MethodGet
+	c.maxURLLength = maxURLLength
+	return c
+}
+
+// WithGraphQLType declares the GraphQL type name of the Go type of v, used in the variable declarations
+// of the operations, e.g. WithGraphQLType(uuid.UUID{}, "uuid"). Call it for each type to build
+// the mapping table. It takes precedence over the GraphQLType method and the Go name of the type.
+func (c *Client) WithGraphQLType(v interface{}, name string) *Client {
+	if c.typeNames == nil {
+		c.typeNames = make(map[reflect.Type]string)
+	}
+	c.typeNames[reflect.TypeOf(v)] = name
+	return c
+}
+
+// WithTypename registers the Go type of v, a struct or a pointer to a struct, as the type of objects
+// whose __typename is typename, e.g. WithTypename("Droid", Droid{}). Call it for each possible type
+// of the unions and interfaces of the schema. The selection set of an interface field of a query,
+// e.g. a field of type Character, selects __typename and the fields of each registered type that implements
+// the interface in an inline fragment, and the field is set to a value of the type of the object.
+func (c *Client) WithTypename(typename string, v interface{}) *Client {
+	c.types = c.types.with(typename, reflect.TypeOf(v))
+	return c
+}
+
 // Query executes a single GraphQL query request,
 // with a query derived from q, populating the response into it.
 // q should be a pointer to struct that corresponds to the GraphQL schema.
-func (c *Client) Query(ctx context.Context, q interface{}, variables map[string]interface{}, options ...Option) error {
+// variables is a map from variable names to values, or a struct whose fields
+// are the variables, named by their graphql tags.
+func (c *Client) Query(ctx context.Context, q interface{}, variables interface{}, options ...Option) error {
 	return c.do(ctx, queryOperation, q, variables, options...)
 }
 
 // NamedQuery executes a single GraphQL query request, with operation name
 //
 // Deprecated: this is the shortcut of Query method, with NewOperationName option
-func (c *Client) NamedQuery(ctx context.Context, name string, q interface{}, variables map[string]interface{}, options ...Option) error {
+func (c *Client) NamedQuery(ctx context.Context, name string, q interface{}, variables interface{}, options ...Option) error {
 	return c.do(ctx, queryOperation, q, variables, append(options, OperationName(name))...)
 }
 
 // Mutate executes a single GraphQL mutation request,
 // with a mutation derived from m, populating the response into it.
 // m should be a pointer to struct that corresponds to the GraphQL schema.
-func (c *Client) Mutate(ctx context.Context, m interface{}, variables map[string]interface{}, options ...Option) error {
+// variables is a map or a struct of variables, like in Query.
+func (c *Client) Mutate(ctx context.Context, m interface{}, variables interface{}, options ...Option) error {
 	return c.do(ctx, mutationOperation, m, variables, options...)
 }
 
 // NamedMutate executes a single GraphQL mutation request, with operation name
 //
 // Deprecated: this is the shortcut of Mutate method, with NewOperationName option
-func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, variables map[string]interface{}, options ...Option) error {
+func (c *Client) NamedMutate(ctx context.Context, name string, m interface{}, variables interface{}, options ...Option) error {
 	return c.do(ctx, mutationOperation, m, variables, append(options, OperationName(name))...)
 }
 
+// QueryPartial executes a single GraphQL query request,
+// with a query derived from q, populating the response into it.
+// Unlike Query, GraphQL errors of a response with partial data don't fail the call.
+// They are returned with the path of the Go field that experienced the error,
+// so callers can tell which parts of q are valid.
+// If the response has no data, the GraphQL errors are returned as Errors error.
+func (c *Client) QueryPartial(ctx context.Context, q interface{}, variables interface{}, options ...Option) ([]FieldError, error) {
+	return c.doPartial(ctx, queryOperation, q, variables, options...)
+}
+
+// MutatePartial executes a single GraphQL mutation request,
+// with a mutation derived from m, populating the response into it.
+// GraphQL errors are returned like in QueryPartial.
+func (c *Client) MutatePartial(ctx context.Context, m interface{}, variables interface{}, options ...Option) ([]FieldError, error) {
+	return c.doPartial(ctx, mutationOperation, m, variables, options...)
+}
+
 // Query executes a single GraphQL query request,
 // with a query derived from q, populating the response into it.
 // q should be a pointer to struct that corresponds to the GraphQL schema.
 // return raw bytes message.
-func (c *Client) QueryRaw(ctx context.Context, q interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
+func (c *Client) QueryRaw(ctx context.Context, q interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
 	return c.doRaw(ctx, queryOperation, q, variables, options...)
 }
 
 // NamedQueryRaw executes a single GraphQL query request, with operation name
 // return raw bytes message.
-func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
+func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
 	return c.doRaw(ctx, queryOperation, q, variables, append(options, OperationName(name))...)
 }
 
@@ -77,150 +164,408 @@ func (c *Client) NamedQueryRaw(ctx context.Context, name string, q interface{},
 // with a mutation derived from m, populating the response into it.
 // m should be a pointer to struct that corresponds to the GraphQL schema.
 // return raw bytes message.
-func (c *Client) MutateRaw(ctx context.Context, m interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
+func (c *Client) MutateRaw(ctx context.Context, m interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
 	return c.doRaw(ctx, mutationOperation, m, variables, options...)
 }
 
 // NamedMutateRaw executes a single GraphQL mutation request, with operation name
 // return raw bytes message.
-func (c *Client) NamedMutateRaw(ctx context.Context, name string, m interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
+func (c *Client) NamedMutateRaw(ctx context.Context, name string, m interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
 	return c.doRaw(ctx, mutationOperation, m, variables, append(options, OperationName(name))...)
 }
 
-// do executes a single GraphQL operation.
-// return raw message and error
-func (c *Client) doRaw(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, options ...Option) (*json.RawMessage, error) {
-	var query string
-	var err error
-	switch op {
-	case queryOperation:
-		query, err = constructQuery(v, variables, options...)
-	case mutationOperation:
-		query, err = constructMutation(v, variables, options...)
+// Exec executes a single GraphQL operation of a handwritten document,
+// such as a query copied from GraphiQL or loaded with go:embed,
+// and populates the response data into v with the same decoding rules as Query.
+// The operation type is read from the document, so mutations are always sent with POST
+// and retried only if the retry policy allows it. Documents with several operations
+// need the OperationName option to select the operation.
+// Options that render the query string, such as OperationDirective, don't apply.
+func (c *Client) Exec(ctx context.Context, query string, v interface{}, variables interface{}, options ...Option) error {
+	optionsOutput, err := constructOptions(options)
+	if err != nil {
+		return err
+	}
+	op, err := documentOperationType(query, optionsOutput.operationName)
+	if err != nil {
+		return err
 	}
+	vars, _, err := variablesMap(variables)
+	if err != nil {
+		return err
+	}
+	in := &requestPayload{
+		Query:         query,
+		Variables:     vars,
+		OperationName: optionsOutput.operationName,
+	}
+	data, errs, err := c.dispatch(ctx, op, v, in, optionsOutput)
+	if err != nil {
+		return err
+	}
+	return c.decodeResult(ctx, data, errs, v)
+}
 
+// doRaw executes a single GraphQL operation.
+// return raw message and error
+func (c *Client) doRaw(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) (*json.RawMessage, error) {
+	data, errs, err := c.request(ctx, op, v, variables, options...)
 	if err != nil {
 		return nil, err
 	}
+	if len(errs) > 0 {
+		return data, errs
+	}
+
+	return data, nil
+}
+
+// do executes a single GraphQL operation and unmarshal json.
+func (c *Client) do(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) error {
+	data, errs, err := c.request(ctx, op, v, variables, options...)
+	if err != nil {
+		return err
+	}
+	return c.decodeResult(ctx, data, errs, v)
+}
 
-	in := struct {
-		Query     string                 `json:"query"`
-		Variables map[string]interface{} `json:"variables,omitempty"`
-	}{
-		Query:     query,
-		Variables: variables,
+// decodeResult unmarshals the response data into v, and returns the GraphQL errors as Errors error.
+func (c *Client) decodeResult(ctx context.Context, data *json.RawMessage, errs Errors, v interface{}) error {
+	if data != nil {
+		err := c.decode(ctx, *data, v)
+		if err != nil {
+			// TODO: Consider including response body in returned error, if deemed helpful.
+			return err
+		}
 	}
-	var buf bytes.Buffer
-	err = json.NewEncoder(&buf).Encode(in)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// doPartial executes a single GraphQL operation, unmarshal json
+// and resolves GraphQL errors against v.
+func (c *Client) doPartial(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) ([]FieldError, error) {
+	data, errs, err := c.request(ctx, op, v, variables, options...)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := ctxhttp.Post(ctx, c.httpClient, c.url, "application/json", &buf)
+	if data == nil {
+		if len(errs) > 0 {
+			return nil, errs
+		}
+		return nil, nil
+	}
+	err = c.decode(ctx, *data, v)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("non-200 OK status code: %v body: %q", resp.Status, body)
+	if len(errs) > 0 {
+		return resolveFieldErrors(v, errs), nil
+	}
+	return nil, nil
+}
+
+// request constructs the query from v, sends it to the GraphQL server
+// and returns the raw "data" and "errors" fields of the response.
+func (c *Client) request(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) (*json.RawMessage, Errors, error) {
+	in, optionsOutput, err := c.construct(ctx, op, v, variables, options...)
+	if err != nil {
+		return nil, nil, err
 	}
-	var out struct {
-		Data   *json.RawMessage
-		Errors errors
-		//Extensions interface{} // Unused.
+	return c.dispatch(ctx, op, v, in, optionsOutput)
+}
+
+// dispatch sends the constructed payload through the middleware chain and the retries,
+// and returns the raw "data" and "errors" fields of the response.
+func (c *Client) dispatch(ctx context.Context, op operationType, v interface{}, in *requestPayload, optionsOutput *constructOptionsOutput) (*json.RawMessage, Errors, error) {
+	method := c.requestMethod(op, optionsOutput.method)
+	handler := func(ctx context.Context, req *Request) (*Response, error) {
+		in := &requestPayload{
+			Query:         req.Query,
+			Variables:     req.Variables,
+			OperationName: req.OperationName,
+		}
+		// files are streamed from their readers, so operations with uploads
+		// are sent once, without batching, persisted queries and retries
+		if len(findUploads(in.Variables)) > 0 {
+			if optionsOutput.attempts != nil {
+				*optionsOutput.attempts = 1
+			}
+			return c.send(ctx, http.MethodPost, in, req.Header)
+		}
+		var out *Response
+		var err error
+		attempt := 0
+		for {
+			attempt++
+			out, err = c.execute(ctx, op, v, in, method, req.Header)
+			retryErr := err
+			if err == nil && len(out.Errors) > 0 {
+				retryErr = out.Errors
+			}
+			if !c.retryPolicy.retry(op, attempt, retryErr) || !c.retryPolicy.wait(ctx, attempt) {
+				break
+			}
+		}
+		if optionsOutput.attempts != nil {
+			*optionsOutput.attempts = attempt
+		}
+		return out, err
 	}
-	err = json.NewDecoder(resp.Body).Decode(&out)
+	out, err := c.chain(handler)(ctx, &Request{
+		OperationType: op.String(),
+		OperationName: in.OperationName,
+		Query:         in.Query,
+		Variables:     in.Variables,
+		Header:        optionsOutput.headers,
+	})
 	if err != nil {
-		// TODO: Consider including response body in returned error, if deemed helpful.
-		return nil, err
+		return nil, nil, err
+	}
+	if out == nil {
+		return nil, nil, fmt.Errorf("empty response of %s operation", op)
+	}
+	err = out.bindExtensions(optionsOutput.extensions)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if len(out.Errors) > 0 {
-		return out.Data, out.Errors
+	return out.Data, out.Errors, nil
+}
+
+// execute sends the payload of a single attempt of the operation.
+func (c *Client) execute(ctx context.Context, op operationType, v interface{}, in *requestPayload, method string, headers http.Header) (*Response, error) {
+	if c.coalescer != nil && op == queryOperation && len(headers) == 0 {
+		return c.coalescer.do(ctx, v, method, in)
 	}
+	return c.executeSingle(ctx, op, v, in, method, headers)
+}
 
-	return out.Data, nil
+// executeSingle sends the payload in a request of its own,
+// with the hash of the query if persisted queries are enabled.
+func (c *Client) executeSingle(ctx context.Context, op operationType, v interface{}, in *requestPayload, method string, headers http.Header) (*Response, error) {
+	if c.persistedQueries != nil && c.persistedQueries.enabled() {
+		return c.requestPersisted(ctx, op, v, in, method, headers)
+	}
+	return c.send(ctx, method, in, headers)
 }
 
-// do executes a single GraphQL operation and unmarshal json.
-func (c *Client) do(ctx context.Context, op operationType, v interface{}, variables map[string]interface{}, options ...Option) error {
+// construct constructs the request payload of the operation derived from v, within a span.
+func (c *Client) construct(ctx context.Context, op operationType, v interface{}, variables interface{}, options ...Option) (*requestPayload, *constructOptionsOutput, error) {
+	_, span := startSpan(c.tracer, ctx, SpanConstruct, map[string]interface{}{
+		AttributeOperationType: op.String(),
+	})
+	types := operationTypes{names: c.typeNames, registry: c.types}
+	in, optionsOutput, err := constructRequest(op, v, variables, types, options...)
+	if err == nil && in.OperationName != "" {
+		span.SetAttribute(AttributeOperationName, in.OperationName)
+	}
+	span.End(err)
+	return in, optionsOutput, err
+}
 
-	var query string
-	var err error
-	switch op {
-	case queryOperation:
-		query, err = constructQuery(v, variables, options...)
-	case mutationOperation:
-		query, err = constructMutation(v, variables, options...)
+// decode unmarshals the data of a response into v, within a span.
+func (c *Client) decode(ctx context.Context, data []byte, v interface{}) error {
+	_, span := startSpan(c.tracer, ctx, SpanDecode, nil)
+	err := jsonutil.UnmarshalGraphQLTypes(data, v, c.types.typesMap())
+	span.End(err)
+	return err
+}
+
+// constructRequest constructs the request payload of the operation derived from v.
+// The Go types of the variables are read from variables into types.
+func constructRequest(op operationType, v interface{}, variables interface{}, types operationTypes, options ...Option) (*requestPayload, *constructOptionsOutput, error) {
+	vars, variableTypes, err := variablesMap(variables)
+	if err != nil {
+		return nil, nil, err
+	}
+	types.variables = variableTypes
+	query, err := constructOperation(op.String(), v, vars, types, options...)
+	if err != nil {
+		return nil, nil, err
 	}
 
+	optionsOutput, err := constructOptions(options)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	in := struct {
-		Query     string                 `json:"query"`
-		Variables map[string]interface{} `json:"variables,omitempty"`
-	}{
-		Query:     query,
-		Variables: variables,
+	return &requestPayload{
+		Query:         query,
+		Variables:     vars,
+		OperationName: optionsOutput.operationName,
+	}, optionsOutput, nil
+}
+
+// requestMethod returns the HTTP method of the operation.
+// Mutations are always sent with POST, because GET requests must not have side effects.
+func (c *Client) requestMethod(op operationType, override string) string {
+	if op != queryOperation {
+		return http.MethodPost
 	}
-	var buf bytes.Buffer
-	err = json.NewEncoder(&buf).Encode(in)
+	if override != "" {
+		return override
+	}
+	return c.queryMethod
+}
+
+// requestPayload is the body of a GraphQL request.
+type requestPayload struct {
+	Query         string                 `json:"query,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Response is the body of a GraphQL response.
+type Response struct {
+	Data       *json.RawMessage
+	Errors     Errors
+	Extensions *json.RawMessage
+}
+
+// bindExtensions decodes the response extensions into v, if both exist.
+func (r *Response) bindExtensions(v interface{}) error {
+	if v == nil || r.Extensions == nil {
+		return nil
+	}
+	err := json.Unmarshal(*r.Extensions, v)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to decode response extensions: %w", err)
+	}
+	return nil
+}
+
+// send sends the payload to the GraphQL server and decodes the response.
+func (c *Client) send(ctx context.Context, method string, in *requestPayload, headers http.Header) (*Response, error) {
+	req, err := c.newRequest(method, in)
+	if err != nil {
+		return nil, err
 	}
-	resp, err := ctxhttp.Post(ctx, c.httpClient, c.url, "application/json", &buf)
+	var out Response
+	err = c.roundTrip(ctx, req, headers, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// roundTrip applies the request modifiers and headers to req, sends it
+// and decodes the JSON response body into out.
+func (c *Client) roundTrip(ctx context.Context, req *http.Request, headers http.Header, out interface{}) error {
+	return c.doHTTP(ctx, req, headers, func(resp *http.Response) error {
+		// TODO: Consider including response body in returned error, if deemed helpful.
+		return json.NewDecoder(resp.Body).Decode(out)
+	})
+}
+
+// doHTTP applies the request modifiers and headers to req, sends it
+// and calls read with the response if the status code is 200 OK.
+func (c *Client) doHTTP(ctx context.Context, req *http.Request, headers http.Header, read func(resp *http.Response) error) (err error) {
+	ctx, span := startSpan(c.tracer, ctx, SpanHTTP, map[string]interface{}{
+		AttributeHTTPMethod: req.Method,
+		AttributeHTTPURL:    c.url,
+	})
+	defer func() {
+		span.End(err)
+	}()
+	if c.tracer != nil {
+		c.tracer.Inject(ctx, req.Header.Set)
+	}
+	for _, modifier := range c.requestModifiers {
+		modifier(req)
+	}
+	for key, values := range headers {
+		req.Header.Del(key)
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	resp, err := ctxhttp.Do(ctx, c.httpClient, req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	span.SetAttribute(AttributeHTTPStatusCode, resp.StatusCode)
 	if resp.StatusCode != http.StatusOK {
 		body, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("non-200 OK status code: %v body: %q", resp.Status, body)
-	}
-	var out struct {
-		Data   *json.RawMessage
-		Errors errors
-		//Extensions interface{} // Unused.
+		return &HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       body,
+		}
 	}
-	err = json.NewDecoder(resp.Body).Decode(&out)
-	if err != nil {
-		// TODO: Consider including response body in returned error, if deemed helpful.
-		return err
+	return read(resp)
+}
+
+// newRequest creates the HTTP request of the payload.
+// Payloads with uploads are sent as multipart requests.
+// With GET method, the payload is encoded into the URL query parameters,
+// unless the URL is longer than the maximum URL length.
+func (c *Client) newRequest(method string, in *requestPayload) (*http.Request, error) {
+	if uploads := findUploads(in.Variables); len(uploads) > 0 {
+		return c.newMultipartRequest(in, uploads)
 	}
-	if out.Data != nil {
-		err := jsonutil.UnmarshalGraphQL(*out.Data, v)
+	if method == http.MethodGet {
+		u, err := encodeQueryURL(c.url, in)
 		if err != nil {
-			// TODO: Consider including response body in returned error, if deemed helpful.
-			return err
+			return nil, err
 		}
+		if len(u) <= c.maxURLLength {
+			return http.NewRequest(http.MethodGet, u, nil)
+		}
+		// The URL is too long, fall back to POST.
 	}
-	if len(out.Errors) > 0 {
-		return out.Errors
-	}
-	return nil
+	return c.newPostRequest(in)
 }
 
-// errors represents the "errors" array in a response from a GraphQL server.
-// If returned via error interface, the slice is expected to contain at least 1 element.
-//
-// Specification: https://facebook.github.io/graphql/#sec-Errors.
-type errors []struct {
-	Message   string
-	Locations []struct {
-		Line   int
-		Column int
+// newPostRequest creates a POST request with the JSON encoded body.
+func (c *Client) newPostRequest(body interface{}) (*http.Request, error) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(body)
+	if err != nil {
+		return nil, err
 	}
+	req, err := http.NewRequest(http.MethodPost, c.url, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
 }
 
-// Error implements error interface.
-func (e errors) Error() string {
-	b := strings.Builder{}
-	for _, err := range e {
-		b.WriteString(fmt.Sprintf("Message: %s, Locations: %+v", err.Message, err.Locations))
+// encodeQueryURL encodes the payload into the query parameters of the GraphQL server URL,
+// following https://graphql.github.io/graphql-over-http/draft/#sec-GET.
+func encodeQueryURL(rawURL string, in *requestPayload) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	params := u.Query()
+	if in.Query != "" {
+		params.Set("query", in.Query)
+	}
+	if len(in.Variables) > 0 {
+		variables, err := json.Marshal(in.Variables)
+		if err != nil {
+			return "", err
+		}
+		params.Set("variables", string(variables))
+	}
+	if in.OperationName != "" {
+		params.Set("operationName", in.OperationName)
+	}
+	if len(in.Extensions) > 0 {
+		extensions, err := json.Marshal(in.Extensions)
+		if err != nil {
+			return "", err
+		}
+		params.Set("extensions", string(extensions))
 	}
-	return b.String()
+	u.RawQuery = params.Encode()
+	return u.String(), nil
 }
 
 type operationType uint8
@@ -230,3 +575,83 @@ const (
 	mutationOperation
 	//subscriptionOperation // Unused.
 )
+
+// documentOperationType returns the type of the operation of a GraphQL document named operationName.
+// If operationName is empty, the document must have a single operation.
+// Fragment definitions are skipped. Subscriptions aren't supported.
+func documentOperationType(document string, operationName string) (operationType, error) {
+	type operation struct {
+		keyword string
+		name    string
+	}
+	var operations []operation
+	p := tagParser{tag: document}
+	for p.skipIgnored(); p.i < len(p.tag); p.skipIgnored() {
+		// query shorthand
+		op := operation{keyword: "query"}
+		if p.tag[p.i] != '{' {
+			start := p.i
+			op.keyword = p.readName()
+			switch op.keyword {
+			case "query", "mutation", "subscription", "fragment":
+			default:
+				if p.i == start {
+					p.i++
+				}
+				return 0, fmt.Errorf("invalid GraphQL document: unexpected %q", document[start:p.i])
+			}
+			p.skipIgnored()
+			op.name = p.readName()
+		}
+		if err := p.skipDefinition(); err != nil {
+			return 0, fmt.Errorf("invalid GraphQL document: %w", err)
+		}
+		if op.keyword != "fragment" {
+			operations = append(operations, op)
+		}
+	}
+
+	var selected *operation
+	switch {
+	case len(operations) == 0:
+		return 0, fmt.Errorf("invalid GraphQL document: no operation found")
+	case operationName != "":
+		for i := range operations {
+			if operations[i].name == operationName {
+				selected = &operations[i]
+				break
+			}
+		}
+		if selected == nil {
+			return 0, fmt.Errorf("invalid GraphQL document: operation %s not found", operationName)
+		}
+	case len(operations) > 1:
+		return 0, fmt.Errorf("invalid GraphQL document: the document has %d operations, use the OperationName option to select one", len(operations))
+	default:
+		selected = &operations[0]
+	}
+	switch selected.keyword {
+	case "mutation":
+		return mutationOperation, nil
+	case "subscription":
+		return 0, fmt.Errorf("subscription operations aren't supported by Client, use SubscriptionClient instead")
+	default:
+		return queryOperation, nil
+	}
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// String returns the GraphQL keyword of the operation type.
+func (op operationType) String() string {
+	switch op {
+	case queryOperation:
+		return "query"
+	case mutationOperation:
+		return "mutation"
+	default:
+		return "unknown"
+	}
+}
@@ -3,11 +3,16 @@ package graphql_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hasura/go-graphql-client"
 )
@@ -215,6 +220,543 @@ func TestClient_Query_emptyVariables(t *testing.T) {
 	}
 }
 
+func TestClient_Query_invalidQuery(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		t.Error("unexpected request")
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name graphql.String
+		} `graphql:"user(login: $login"`
+	}
+	err := client.Query(context.Background(), &q, map[string]interface{}{"login": graphql.String("gopher")})
+	if got, want := fmt.Sprint(err), `invalid query field User: malformed graphql tag "user(login: $login": unclosed '('`; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Query_graphQLType(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query ($id:uuid!){user(id: $id){name}}","variables":{"id":"6ba7b810"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	type userID string
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithGraphQLType(userID(""), "uuid")
+
+	var q struct {
+		User struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	err := client.Query(context.Background(), &q, map[string]interface{}{"id": userID("6ba7b810")})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type graphQLTyper interface {
+	GraphQLType() string
+}
+
+type uuidT string
+
+func (uuidT) GraphQLType() string { return "uuid" }
+
+func TestClient_Query_graphQLTypeInterface(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query ($id:uuid!){user(id: $id){name}}","variables":{"id":"x"}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	err := client.Query(context.Background(), &q, struct {
+		ID graphQLTyper `graphql:"id"`
+	}{ID: uuidT("x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.Query(context.Background(), &q, struct {
+		ID graphQLTyper `graphql:"id"`
+	}{})
+	if want := "invalid variable id: the GraphQL type of interface graphql_test.graphQLTyper is declared by its value, which is nil"; err == nil || err.Error() != want {
+		t.Errorf("got error: %v, want: %s", err, want)
+	}
+}
+
+type character interface {
+	characterName() string
+}
+
+type human struct {
+	Name   string
+	Height float64
+}
+
+func (h human) characterName() string { return h.Name }
+
+type droid struct {
+	Name            string
+	PrimaryFunction string
+}
+
+func (d *droid) characterName() string { return d.Name }
+
+func TestClient_Query_typename(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"{hero{__typename,... on Droid{name,primaryFunction},... on Human{name,height}},search{__typename,... on Droid{name,primaryFunction},... on Human{name,height}}}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {
+			"hero": {"__typename": "Droid", "name": "R2-D2", "primaryFunction": "Astromech"},
+			"search": [
+				{"__typename": "Human", "name": "Luke Skywalker", "height": 1.72},
+				null
+			]
+		}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithTypename("Human", human{}).
+		WithTypename("Droid", &droid{})
+
+	var q struct {
+		Hero   character
+		Search []character
+	}
+	err := client.Query(context.Background(), &q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.Hero, character(&droid{Name: "R2-D2", PrimaryFunction: "Astromech"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got hero: %#v, want: %#v", got, want)
+	}
+	if got, want := q.Search, []character{human{Name: "Luke Skywalker", Height: 1.72}, nil}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got search: %#v, want: %#v", got, want)
+	}
+}
+
+func TestClient_Query_structVariables(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		body := mustRead(req.Body)
+		if got, want := body, `{"query":"query ($first:Int$id:ID!){user(id: $id){followers(first: $first){totalCount}}}","variables":{"first":null,"id":1}}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"followers": {"totalCount": 2}}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Followers struct {
+				TotalCount graphql.Int
+			} `graphql:"followers(first: $first)"`
+		} `graphql:"user(id: $id)"`
+	}
+	// the ID type of the field is declared, rather than the int type of the value
+	variables := struct {
+		ID    graphql.ID
+		First *graphql.Int
+	}{
+		ID: 1,
+	}
+	err := client.Query(context.Background(), &q, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Followers.TotalCount, graphql.Int(2); got != want {
+		t.Errorf("got q.User.Followers.TotalCount: %v, want: %v", got, want)
+	}
+}
+
+func TestClient_Query_requestHeaders(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got Authorization header: %q, want: %q", got, want)
+		}
+		if got, want := req.Header.Get("X-Hasura-Role"), "editor"; got != want {
+			t.Errorf("got X-Hasura-Role header: %q, want: %q", got, want)
+		}
+		if got, want := req.Header.Get("Content-Type"), "application/json"; got != want {
+			t.Errorf("got Content-Type header: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithRequestModifier(func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer token")
+			r.Header.Set("X-Hasura-Role", "user")
+		})
+
+	var q struct {
+		User struct {
+			Name string
+		}
+	}
+	err := client.Query(context.Background(), &q, nil, graphql.RequestHeader("x-hasura-role", "editor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, "Gopher"; got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Mutate_errorExtensions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{
+			"errors": [
+				{
+					"message": "Uniqueness violation. duplicate key value violates unique constraint \"users_pkey\"",
+					"path": ["insert_users_one", 0],
+					"extensions": {
+						"code": "constraint-violation",
+						"path": "$.selectionSet.insert_users_one.args.object"
+					}
+				}
+			]
+		}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var m struct {
+		InsertUsersOne struct {
+			ID graphql.ID
+		} `graphql:"insert_users_one(object: {id: 1})"`
+	}
+	err := client.Mutate(context.Background(), &m, nil)
+	if err == nil {
+		t.Fatal("got error: nil, want: non-nil")
+	}
+
+	var errs graphql.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error: %T, want: graphql.Errors", err)
+	}
+	if got, want := len(errs), 1; got != want {
+		t.Fatalf("got %d errors, want: %d", got, want)
+	}
+	if got, want := errs[0].Code(), graphql.ErrorCodeConstraintViolation; got != want {
+		t.Errorf("got code: %q, want: %q", got, want)
+	}
+	if got, want := fmt.Sprint(errs[0].Path), "[insert_users_one 0]"; got != want {
+		t.Errorf("got path: %s, want: %s", got, want)
+	}
+	var gqlErr graphql.Error
+	if !errors.As(err, &gqlErr) || gqlErr.Extensions["path"] != "$.selectionSet.insert_users_one.args.object" {
+		t.Errorf("got wrong graphql.Error: %+v", gqlErr)
+	}
+	if !graphql.IsConstraintViolation(err) {
+		t.Error("got IsConstraintViolation: false, want: true")
+	}
+	if graphql.IsAccessDenied(err) || graphql.IsValidationFailed(err) {
+		t.Error("got access-denied or validation-failed classification, want: none")
+	}
+	if graphql.IsConstraintViolation(fmt.Errorf("some error")) {
+		t.Error("got IsConstraintViolation: true for non-GraphQL error, want: false")
+	}
+}
+
+func TestClient_QueryPartial(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{
+			"data": {
+				"viewer": {
+					"login": "gopher",
+					"repositories": [
+						{"name": "go", "stars": 100},
+						{"name": "secret", "stars": null}
+					]
+				}
+			},
+			"errors": [
+				{
+					"message": "field 'stars' is forbidden",
+					"path": ["viewer", "repositories", 1, "stars"],
+					"extensions": {"code": "access-denied"}
+				},
+				{
+					"message": "rate limited"
+				}
+			]
+		}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		Viewer struct {
+			Login        graphql.String
+			Repositories []struct {
+				Name  graphql.String
+				Stars *graphql.Int
+			} `graphql:"repositories(first: 2)"`
+		}
+	}
+	fieldErrs, err := client.QueryPartial(context.Background(), &q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(fieldErrs), 2; got != want {
+		t.Fatalf("got %d field errors, want: %d", got, want)
+	}
+	if got, want := fieldErrs[0].FieldPath, "Viewer.Repositories[1].Stars"; got != want {
+		t.Errorf("got field path: %q, want: %q", got, want)
+	}
+	if got, want := fieldErrs[0].Code(), graphql.ErrorCodeAccessDenied; got != want {
+		t.Errorf("got code: %q, want: %q", got, want)
+	}
+	if got, want := fieldErrs[1].FieldPath, ""; got != want {
+		t.Errorf("got field path: %q, want: %q", got, want)
+	}
+	if q.Viewer.Login != "gopher" || len(q.Viewer.Repositories) != 2 || *q.Viewer.Repositories[0].Stars != 100 {
+		t.Errorf("got wrong q.Viewer: %+v", q.Viewer)
+	}
+	if q.Viewer.Repositories[1].Stars != nil {
+		t.Errorf("got non-nil q.Viewer.Repositories[1].Stars: %v, want: nil", *q.Viewer.Repositories[1].Stars)
+	}
+}
+
+func TestClient_Query_bindExtensions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{
+			"data": {"user": {"name": "Gopher"}},
+			"extensions": {"cost": {"requestedQueryCost": 3, "actualQueryCost": 2}, "tracing": {"duration": 1500}}
+		}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		User struct {
+			Name string
+		}
+	}
+	var extensions struct {
+		Cost struct {
+			RequestedQueryCost int
+			ActualQueryCost    int
+		}
+		Tracing map[string]interface{}
+	}
+	err := client.Query(context.Background(), &q, nil, graphql.BindExtensions(&extensions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, "Gopher"; got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+	if extensions.Cost.RequestedQueryCost != 3 || extensions.Cost.ActualQueryCost != 2 {
+		t.Errorf("got wrong extensions.Cost: %+v", extensions.Cost)
+	}
+	if got, want := extensions.Tracing["duration"], float64(1500); got != want {
+		t.Errorf("got extensions.Tracing[duration]: %v, want: %v", got, want)
+	}
+
+	var rawExtensions json.RawMessage
+	_, err = client.QueryRaw(context.Background(), &q, nil, graphql.BindExtensions(&rawExtensions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rawExtensions) == 0 {
+		t.Error("got empty raw extensions")
+	}
+}
+
+func TestClient_Query_getMethod(t *testing.T) {
+	var methods []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		methods = append(methods, req.Method)
+		if req.Method == http.MethodGet {
+			params := req.URL.Query()
+			if got, want := params.Get("query"), `query GetUser($id:ID!){user(id: $id){name}}`; got != want {
+				t.Errorf("got query: %q, want: %q", got, want)
+			}
+			if got, want := params.Get("variables"), `{"id":"1"}`; got != want {
+				t.Errorf("got variables: %q, want: %q", got, want)
+			}
+			if got, want := params.Get("operationName"), "GetUser"; got != want {
+				t.Errorf("got operationName: %q, want: %q", got, want)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithGETQueries(200)
+
+	var q struct {
+		User struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	variables := map[string]interface{}{
+		"id": graphql.ID("1"),
+	}
+	err := client.Query(context.Background(), &q, variables, graphql.OperationName("GetUser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Query(context.Background(), &q, variables, graphql.OperationName("GetUser"), graphql.RequestMethod(http.MethodPost))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The URL is longer than 200 characters.
+	err = client.Query(context.Background(), &q, map[string]interface{}{
+		"id": graphql.ID(strings.Repeat("a", 200)),
+	}, graphql.OperationName("GetUser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Mutate(context.Background(), &q, variables, graphql.OperationName("GetUser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := fmt.Sprint(methods), "[GET POST POST POST]"; got != want {
+		t.Errorf("got methods: %s, want: %s", got, want)
+	}
+}
+
+func TestClient_Exec(t *testing.T) {
+	const document = `
+# fragments are defined before the operation
+fragment userFields on User {
+	name
+}
+
+mutation UpdateUser($id: ID!) {
+	updateUser(id: $id) { ...userFields }
+}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Method, http.MethodPost; got != want {
+			t.Errorf("got method: %s, want: %s", got, want)
+		}
+		var in struct {
+			Query         string
+			Variables     map[string]interface{}
+			OperationName string
+		}
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		if in.Query != document || in.OperationName != "UpdateUser" || in.Variables["id"] != "1" {
+			t.Errorf("got request: %+v", in)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"updateUser": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithGETQueries(0)
+
+	var m struct {
+		UpdateUser struct {
+			Name string
+		}
+	}
+	err := client.Exec(context.Background(), document, &m, map[string]interface{}{"id": "1"}, graphql.OperationName("UpdateUser"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.UpdateUser.Name, "Gopher"; got != want {
+		t.Errorf("got m.UpdateUser.Name: %q, want: %q", got, want)
+	}
+
+	err = client.Exec(context.Background(), "subscription { user { name } }", &m, nil)
+	if err == nil {
+		t.Error("got nil error for subscription, want: non-nil")
+	}
+}
+
+func TestClient_Exec_operationName(t *testing.T) {
+	const document = `
+query A { user { name } }
+fragment F on User { f(x: "}") }
+mutation B { updateUser { name } }`
+	var methods []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		methods = append(methods, req.Method)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithGETQueries(0).
+		WithRetry(graphql.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	var m struct {
+		UpdateUser struct {
+			Name string
+		}
+	}
+	// the mutation is sent with POST, and isn't retried
+	err := client.Exec(context.Background(), document, &m, nil, graphql.OperationName("B"))
+	if err == nil {
+		t.Fatal("got nil error, want: non-nil")
+	}
+	if got, want := fmt.Sprint(methods), "[POST]"; got != want {
+		t.Errorf("got methods: %s, want: %s", got, want)
+	}
+
+	err = client.Exec(context.Background(), document, &m, nil)
+	if want := "invalid GraphQL document: the document has 2 operations, use the OperationName option to select one"; err == nil || err.Error() != want {
+		t.Errorf("got error: %v, want: %s", err, want)
+	}
+	err = client.Exec(context.Background(), document, &m, nil, graphql.OperationName("C"))
+	if want := "invalid GraphQL document: operation C not found"; err == nil || err.Error() != want {
+		t.Errorf("got error: %v, want: %s", err, want)
+	}
+}
+
+func TestErrors_As(t *testing.T) {
+	errs := graphql.Errors{{Message: "first"}, {Message: "second"}}
+	var gqlErr graphql.Error
+	// errors.As calls the As method on every Go version
+	if !errs.As(&gqlErr) || gqlErr.Message != "first" {
+		t.Errorf("got As: %+v, want: first error", gqlErr)
+	}
+	if !errors.As(fmt.Errorf("query failed: %w", errs), &gqlErr) || gqlErr.Message != "first" {
+		t.Errorf("got errors.As: %+v, want: first error", gqlErr)
+	}
+	var httpErr *graphql.HTTPError
+	if errs.As(&httpErr) {
+		t.Error("got As *HTTPError: true, want: false")
+	}
+	if (graphql.Errors{}).As(&gqlErr) {
+		t.Error("got As of empty Errors: true, want: false")
+	}
+}
+
 // localRoundTripper is an http.RoundTripper that executes HTTP transactions
 // by using handler directly, instead of going over an HTTP connection.
 type localRoundTripper struct {
@@ -0,0 +1,290 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	"github.com/hasura/go-graphql-client/internal/jsonutil"
+)
+
+// Incremental delivery follows the multipart/mixed format of the @defer and @stream RFC
+// https://github.com/graphql/graphql-wg/blob/main/rfcs/DeferStream.md
+
+// incrementalAccept is the Accept header that negotiates incremental delivery.
+const incrementalAccept = "multipart/mixed; deferSpec=20220824, application/json"
+
+// IncrementalPatch describes a payload of an incremental delivery response,
+// after it has been applied to the query struct.
+type IncrementalPatch struct {
+	// Path is the response path of the deferred fragment or the streamed list items.
+	// It's nil for the initial payload, and for a last payload without results.
+	Path []interface{}
+	// Label is the label argument of the @defer or @stream directive, if any.
+	Label string
+	// Errors are the GraphQL errors of the payload.
+	Errors Errors
+	// HasNext is false for the last payload. The operation is complete after it.
+	HasNext bool
+}
+
+// IncrementalHandler is called after each payload of an incremental delivery response.
+// If it returns an error, the response is closed and the error is returned to the caller.
+type IncrementalHandler func(patch IncrementalPatch) error
+
+// incrementalPayload is a part of a multipart/mixed response.
+type incrementalPayload struct {
+	Data        *json.RawMessage
+	Errors      Errors
+	Extensions  *json.RawMessage
+	HasNext     *bool `json:"hasNext"`
+	Incremental []incrementalResult
+
+	// fields of subsequent payloads in the format of the first version of the RFC,
+	// that have no incremental array
+	Items []json.RawMessage
+	Path  []interface{}
+	Label string
+}
+
+// incrementalResult is a deferred fragment or streamed list items.
+type incrementalResult struct {
+	Data       *json.RawMessage
+	Items      []json.RawMessage
+	Path       []interface{}
+	Label      string
+	Errors     Errors
+	Extensions *json.RawMessage
+}
+
+// QueryIncremental executes a single GraphQL query request with @defer or @stream directives,
+// with a query derived from q. Each payload of the response is applied to q,
+// then handler is called, so that callers can read the parts of q that have arrived.
+// It returns after the last payload, with the GraphQL errors of all payloads as Errors error.
+//
+// If the server doesn't support incremental delivery, handler is called once with the complete response.
+func (c *Client) QueryIncremental(ctx context.Context, q interface{}, variables interface{}, handler IncrementalHandler, options ...Option) error {
+	return c.doIncremental(ctx, queryOperation, q, variables, handler, options...)
+}
+
+// MutateIncremental executes a single GraphQL mutation request with @defer or @stream directives,
+// with a mutation derived from m. Payloads are handled like in QueryIncremental.
+func (c *Client) MutateIncremental(ctx context.Context, m interface{}, variables interface{}, handler IncrementalHandler, options ...Option) error {
+	return c.doIncremental(ctx, mutationOperation, m, variables, handler, options...)
+}
+
+// doIncremental executes a single GraphQL operation with incremental delivery.
+// Payloads can't be delivered twice, so the operation isn't batched,
+// sent as a persisted query or retried.
+func (c *Client) doIncremental(ctx context.Context, op operationType, v interface{}, variables interface{}, handler IncrementalHandler, options ...Option) error {
+	in, optionsOutput, err := c.construct(ctx, op, v, variables, options...)
+	if err != nil {
+		return err
+	}
+
+	method := c.requestMethod(op, optionsOutput.method)
+	next := func(ctx context.Context, req *Request) (*Response, error) {
+		in := &requestPayload{
+			Query:         req.Query,
+			Variables:     req.Variables,
+			OperationName: req.OperationName,
+		}
+		ir := &incrementalReader{
+			client:     c,
+			ctx:        ctx,
+			v:          v,
+			extensions: optionsOutput.extensions,
+			handler:    handler,
+		}
+		err := ir.send(method, in, req.Header)
+		return &ir.out, err
+	}
+	out, err := c.chain(next)(ctx, &Request{
+		OperationType: op.String(),
+		OperationName: in.OperationName,
+		Query:         in.Query,
+		Variables:     in.Variables,
+		Header:        optionsOutput.headers,
+	})
+	if optionsOutput.attempts != nil {
+		*optionsOutput.attempts = 1
+	}
+	if err != nil {
+		return err
+	}
+	if out != nil && len(out.Errors) > 0 {
+		return out.Errors
+	}
+	return nil
+}
+
+// incrementalReader applies the payloads of an incremental delivery response to v.
+type incrementalReader struct {
+	client     *Client
+	ctx        context.Context
+	v          interface{}
+	extensions interface{}
+	handler    IncrementalHandler
+
+	// out has the data of the initial payload, and the errors and the last extensions of all payloads
+	out Response
+}
+
+// send sends the payload, negotiating incremental delivery, and reads the response.
+func (ir *incrementalReader) send(method string, in *requestPayload, headers http.Header) error {
+	req, err := ir.client.newRequest(method, in)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", incrementalAccept)
+	return ir.client.doHTTP(ir.ctx, req, headers, ir.read)
+}
+
+// read reads the response, a multipart/mixed response of incremental payloads,
+// or a single JSON response if the server doesn't support incremental delivery.
+func (ir *incrementalReader) read(resp *http.Response) error {
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		var payload incrementalPayload
+		err := json.NewDecoder(resp.Body).Decode(&payload)
+		if err != nil {
+			return err
+		}
+		payload.HasNext = new(bool)
+		_, err = ir.apply(payload)
+		return err
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return errors.New("invalid multipart response: missing boundary")
+	}
+
+	mr := multipart.NewReader(resp.Body, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return errors.New("invalid multipart response: unexpected end of response")
+		}
+		if err != nil {
+			return err
+		}
+		var payload incrementalPayload
+		err = json.NewDecoder(part).Decode(&payload)
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if payload.HasNext == nil && payload.Data == nil && len(payload.Incremental) == 0 && len(payload.Errors) == 0 {
+			// heartbeat
+			continue
+		}
+		hasNext, err := ir.apply(payload)
+		if err != nil || !hasNext {
+			return err
+		}
+	}
+}
+
+// apply applies the payload to v and calls the handler for each result.
+// It reports whether more payloads follow.
+func (ir *incrementalReader) apply(payload incrementalPayload) (bool, error) {
+	hasNext := payload.HasNext != nil && *payload.HasNext
+	ir.out.Errors = append(ir.out.Errors, payload.Errors...)
+	if err := ir.bindExtensions(payload.Extensions); err != nil {
+		return false, err
+	}
+
+	results := payload.Incremental
+	if payload.Path != nil {
+		// a subsequent payload in the format of the first version of the RFC
+		results = append(results, incrementalResult{
+			Data:  payload.Data,
+			Items: payload.Items,
+			Path:  payload.Path,
+			Label: payload.Label,
+		})
+	} else if payload.Data != nil || len(payload.Incremental) == 0 {
+		// the initial payload
+		if payload.Data != nil {
+			ir.out.Data = payload.Data
+			if err := ir.client.decode(ir.ctx, *payload.Data, ir.v); err != nil {
+				return false, err
+			}
+		}
+		patch := IncrementalPatch{
+			Errors:  payload.Errors,
+			HasNext: hasNext || len(results) > 0,
+		}
+		if err := ir.handler(patch); err != nil {
+			return false, err
+		}
+	}
+
+	for i, result := range results {
+		if err := ir.applyResult(result); err != nil {
+			return false, err
+		}
+		ir.out.Errors = append(ir.out.Errors, result.Errors...)
+		if err := ir.bindExtensions(result.Extensions); err != nil {
+			return false, err
+		}
+		patch := IncrementalPatch{
+			Path:    result.Path,
+			Label:   result.Label,
+			Errors:  result.Errors,
+			HasNext: hasNext || i < len(results)-1,
+		}
+		if err := ir.handler(patch); err != nil {
+			return false, err
+		}
+	}
+	return hasNext, nil
+}
+
+// applyResult decodes the data of a deferred fragment, or the streamed items, into v at the result path.
+func (ir *incrementalReader) applyResult(result incrementalResult) error {
+	_, span := startSpan(ir.client.tracer, ir.ctx, SpanDecode, nil)
+	err := ir.decodeResult(result)
+	span.End(err)
+	return err
+}
+
+func (ir *incrementalReader) decodeResult(result incrementalResult) error {
+	if result.Data != nil {
+		return jsonutil.UnmarshalGraphQLPath(*result.Data, ir.v, result.Path, ir.client.types.typesMap())
+	}
+	if len(result.Items) == 0 {
+		return nil
+	}
+	// the path of streamed items ends with the index of the first item
+	if len(result.Path) == 0 {
+		return fmt.Errorf("invalid path of streamed items: %v", result.Path)
+	}
+	listPath := result.Path[:len(result.Path)-1]
+	index, ok := result.Path[len(result.Path)-1].(float64)
+	if !ok {
+		return fmt.Errorf("invalid path of streamed items: %v", result.Path)
+	}
+	for i, item := range result.Items {
+		path := append(listPath[:len(listPath):len(listPath)], int(index)+i)
+		if err := jsonutil.UnmarshalGraphQLPath(item, ir.v, path, ir.client.types.typesMap()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ir *incrementalReader) bindExtensions(extensions *json.RawMessage) error {
+	if extensions == nil {
+		return nil
+	}
+	ir.out.Extensions = extensions
+	return ir.out.bindExtensions(ir.extensions)
+}
@@ -0,0 +1,94 @@
+package graphql_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+func TestClient_QueryIncremental(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("Accept"), "multipart/mixed; deferSpec=20220824, application/json"; got != want {
+			t.Errorf("got Accept header: %q, want: %q", got, want)
+		}
+		if got, want := mustRead(req.Body), `{"query":"{hero{name,friends @stream(initialCount: 1){name},... on Human @defer(label: \"bio\"){bio}}}"}`+"\n"; got != want {
+			t.Errorf("got body: %v, want %v", got, want)
+		}
+		w.Header().Set("Content-Type", `multipart/mixed; boundary="-"; deferSpec=20220824`)
+		mustWrite(w, "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"data": {"hero": {"name": "Luke", "friends": [{"name": "Han"}]}}, "hasNext": true}`+
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{}`+
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"incremental": [{"items": [{"name": "Leia"}, {"name": "Chewie"}], "path": ["hero", "friends", 1]}], "hasNext": true}`+
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"incremental": [{"data": {"bio": "Jedi"}, "path": ["hero"], "label": "bio", "errors": [{"message": "partial bio"}]}], "hasNext": false}`+
+			"\r\n-----\r\n")
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		Hero struct {
+			Name    string
+			Friends []struct {
+				Name string
+			} `graphql:"friends @stream(initialCount: 1)"`
+			Human struct {
+				Bio string
+			} `graphql:"... on Human @defer(label: \"bio\")"`
+		}
+	}
+	var patches []string
+	err := client.QueryIncremental(context.Background(), &q, nil, func(patch graphql.IncrementalPatch) error {
+		patches = append(patches, fmt.Sprintf("%v %q %v: %s %d %q", patch.Path, patch.Label, patch.HasNext, q.Hero.Name, len(q.Hero.Friends), q.Hero.Human.Bio))
+		return nil
+	})
+	if got, want := fmt.Sprint(err), "Message: partial bio, Locations: []"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	want := []string{
+		`[] "" true: Luke 1 ""`,
+		`[hero friends 1] "" true: Luke 3 ""`,
+		`[hero] "bio" false: Luke 3 "Jedi"`,
+	}
+	if !reflect.DeepEqual(patches, want) {
+		t.Errorf("got patches: %q, want: %q", patches, want)
+	}
+	if got, want := q.Hero.Friends[2].Name, "Chewie"; got != want {
+		t.Errorf("got q.Hero.Friends[2].Name: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_QueryIncremental_notSupported(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"hero": {"name": "Luke"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	var q struct {
+		Hero struct {
+			Name string
+		}
+	}
+	calls := 0
+	err := client.QueryIncremental(context.Background(), &q, nil, func(patch graphql.IncrementalPatch) error {
+		calls++
+		if patch.HasNext {
+			t.Error("got HasNext: true, want: false")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || q.Hero.Name != "Luke" {
+		t.Errorf("got %d calls, q.Hero.Name: %q, want: 1 call, %q", calls, q.Hero.Name, "Luke")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -18,9 +19,20 @@ import (
 // The implementation is created on top of the JSON tokenizer available
 // in "encoding/json".Decoder.
 func UnmarshalGraphQL(data []byte, v interface{}) error {
+	return UnmarshalGraphQLTypes(data, v, nil)
+}
+
+// UnmarshalGraphQLTypes is like UnmarshalGraphQL, but it decodes the objects of interface values
+// with methods into the Go types of types, by the __typename of the objects.
+func UnmarshalGraphQLTypes(data []byte, v interface{}, types map[string]reflect.Type) error {
+	return unmarshalGraphQL(&decoder{types: types}, data, v)
+}
+
+func unmarshalGraphQL(d *decoder, data []byte, v interface{}) error {
 	dec := json.NewDecoder(bytes.NewReader(data))
 	dec.UseNumber()
-	err := (&decoder{tokenizer: dec}).Decode(v)
+	d.tokenizer = dec
+	err := d.Decode(v)
 	if err != nil {
 		return err
 	}
@@ -55,6 +67,22 @@ type decoder struct {
 	// a single JSON value into multiple GraphQL fragments or embedded structs, so
 	// we keep track of them all.
 	vs []stack
+
+	// Map values being decoded, in the order they were found.
+	mapEntries []mapEntry
+
+	// types maps the __typename of objects to the Go types of interface values.
+	types map[string]reflect.Type
+	// typenameSelected is true if the __typename of the top-level object is selected
+	// to find its Go type, so it's not a field of the type.
+	typenameSelected bool
+}
+
+// mapEntry is a value of map m being decoded. Map values aren't addressable,
+// so the value is decoded into a copy, that is stored in m at the end of the JSON object at depth.
+type mapEntry struct {
+	m, key, value reflect.Value
+	depth         int
 }
 
 type stack []reflect.Value
@@ -73,6 +101,20 @@ func (d *decoder) Decode(v interface{}) error {
 	if rv.Kind() != reflect.Ptr {
 		return fmt.Errorf("cannot decode into non-pointer %T", v)
 	}
+	if isGenericValue(rv.Elem()) {
+		var data json.RawMessage
+		if err := d.tokenizer.Decode(&data); err != nil {
+			return err
+		}
+		return unmarshalValue(data, rv.Elem())
+	}
+	if d.isPolymorphic(rv.Elem().Type()) {
+		var data json.RawMessage
+		if err := d.tokenizer.Decode(&data); err != nil {
+			return err
+		}
+		return d.decodePolymorphic(data, rv.Elem())
+	}
 	d.vs = []stack{{rv.Elem()}}
 	return d.decode()
 }
@@ -101,9 +143,14 @@ func (d *decoder) decode() error {
 			if !ok {
 				return errors.New("unexpected non-key in JSON input")
 			}
+			d.allocFragments(key)
 			someFieldExist := false
 			// If one field is raw all must be treated as raw
 			rawMessage := false
+			// Values without selection sets are decoded as generic JSON values
+			allGeneric := true
+			// Interface values are decoded into the Go types of the __typename of the objects
+			polymorphic := false
 			for i := range d.vs {
 				v := d.vs[i].Top()
 				for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
@@ -125,14 +172,28 @@ func (d *decoder) decode() error {
 					if f.IsValid() {
 						someFieldExist = true
 					}
+				case reflect.Map:
+					f = d.mapValueByGraphQLName(v, key)
+					if f.IsValid() {
+						someFieldExist = true
+						if f.Type() == rawMessageValue.Type() {
+							rawMessage = true
+						}
+					}
+				}
+				if f.IsValid() && !isGenericValue(f) {
+					allGeneric = false
+				}
+				if f.IsValid() && d.isPolymorphic(f.Type()) {
+					polymorphic = true
 				}
 				d.vs[i] = append(d.vs[i], f)
 			}
-			if !someFieldExist {
+			if !someFieldExist && !(d.typenameSelected && key == "__typename" && len(d.parseState) == 1) {
 				return fmt.Errorf("struct field for %q doesn't exist in any of %v places to unmarshal", key, len(d.vs))
 			}
 
-			if rawMessage {
+			if rawMessage || allGeneric || polymorphic {
 				// Read the next complete object from the json stream
 				var data json.RawMessage
 				d.tokenizer.Decode(&data)
@@ -184,7 +245,12 @@ func (d *decoder) decode() error {
 				if !v.IsValid() {
 					continue
 				}
-				err := unmarshalValue(tok, v)
+				var err error
+				if data, ok := tok.(json.RawMessage); ok && d.isPolymorphic(v.Type()) {
+					err = d.decodePolymorphic(data, v)
+				} else {
+					err = unmarshalValue(tok, v)
+				}
 				if err != nil {
 					return err
 				}
@@ -207,34 +273,7 @@ func (d *decoder) decode() error {
 						v.Set(reflect.New(v.Type().Elem())) // v = new(T).
 					}
 				}
-				// Find GraphQL fragments/embedded structs recursively, adding to frontier
-				// as new ones are discovered and exploring them further.
-				for len(frontier) > 0 {
-					v := frontier[0]
-					frontier = frontier[1:]
-					for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
-						v = v.Elem()
-					}
-					if v.Kind() == reflect.Struct {
-						for i := 0; i < v.NumField(); i++ {
-							if isGraphQLFragment(v.Type().Field(i)) || v.Type().Field(i).Anonymous {
-								// Add GraphQL fragment or embedded struct.
-								d.vs = append(d.vs, []reflect.Value{v.Field(i)})
-								frontier = append(frontier, v.Field(i))
-							}
-						}
-					} else if isOrderedMap(v) {
-						for i := 0; i < v.Len(); i++ {
-							pair := v.Index(i)
-							key, val := pair.Index(0), pair.Index(1)
-							if keyForGraphQLFragment(key.Interface().(string)) {
-								// Add GraphQL fragment or embedded struct.
-								d.vs = append(d.vs, []reflect.Value{val})
-								frontier = append(frontier, val)
-							}
-						}
-					}
-				}
+				d.addFragments(frontier)
 			case '[':
 				// Start of array.
 
@@ -270,6 +309,7 @@ func (d *decoder) decode() error {
 				}
 			case '}':
 				// End of object.
+				d.storeMapEntries()
 				d.popAllVs()
 				d.popState()
 			case ']':
@@ -287,18 +327,249 @@ func (d *decoder) decode() error {
 	return nil
 }
 
+// addFragments finds GraphQL fragments/embedded structs of the values of frontier recursively,
+// adding them to d.vs and to frontier as new ones are discovered and exploring them further.
+func (d *decoder) addFragments(frontier []reflect.Value) {
+	for len(frontier) > 0 {
+		v := frontier[0]
+		frontier = frontier[1:]
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Struct {
+			for i := 0; i < v.NumField(); i++ {
+				if isGraphQLFragment(v.Type().Field(i)) || v.Type().Field(i).Anonymous {
+					// Add GraphQL fragment or embedded struct.
+					d.vs = append(d.vs, []reflect.Value{v.Field(i)})
+					frontier = append(frontier, v.Field(i))
+				}
+			}
+		} else if isOrderedMap(v) {
+			for i := 0; i < v.Len(); i++ {
+				pair := v.Index(i)
+				key, val := pair.Index(0), pair.Index(1)
+				if keyForGraphQLFragment(key.Interface().(string)) {
+					// Add GraphQL fragment or embedded struct.
+					d.vs = append(d.vs, []reflect.Value{val})
+					frontier = append(frontier, val)
+				}
+			}
+		} else if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+			for _, key := range sortedMapKeys(v) {
+				if keyForGraphQLFragment(key.String()) {
+					// Add GraphQL fragment.
+					val := d.mapEntryValue(v, key)
+					d.vs = append(d.vs, []reflect.Value{val})
+					frontier = append(frontier, val)
+				}
+			}
+		}
+	}
+}
+
+// allocFragments allocates the nil pointers of GraphQL fragments/embedded structs of d.vs
+// that have a field with GraphQL name, adding their fragments to d.vs.
+// Fragments without fields in the response stay nil.
+func (d *decoder) allocFragments(name string) {
+	for i := 0; i < len(d.vs); i++ {
+		v := d.vs[i].Top()
+		if v.Kind() != reflect.Ptr || !v.IsNil() || !v.CanSet() || v.Type().Elem().Kind() != reflect.Struct {
+			continue
+		}
+		if _, t, _ := structFieldByGraphQLName(v.Type().Elem(), reflect.Value{}, name); t == nil {
+			continue
+		}
+		v.Set(reflect.New(v.Type().Elem())) // v = new(T).
+		d.addFragments([]reflect.Value{v})
+	}
+}
+
 func copyTemplate(template reflect.Value) (reflect.Value, error) {
 	if isOrderedMap(template) {
 		// copy slice if it's actually an ordered map
 		return copyOrderedMap(template), nil
 	}
 	if template.Kind() == reflect.Map {
-		return reflect.Value{}, fmt.Errorf("unsupported template type `%v`, use [][2]interface{} for ordered map instead", template.Type())
+		// copy map, so that each item is decoded into a map of its own
+		return copyMap(template), nil
 	}
 	// don't need to copy regular slice
 	return template, nil
 }
 
+// copyMap copies map m, including the nested maps and the values of pointers,
+// so that the copy doesn't share values with m.
+func copyMap(m reflect.Value) reflect.Value {
+	if m.IsNil() {
+		return m
+	}
+	newMap := reflect.MakeMapWithSize(m.Type(), m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		newMap.SetMapIndex(iter.Key(), copyMapValue(iter.Value()))
+	}
+	return newMap
+}
+
+func copyMapValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		return copyMapValue(v.Elem())
+	case reflect.Map:
+		return copyMap(v)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type().Elem())
+		copied.Elem().Set(copyMapValue(v.Elem()))
+		return copied
+	}
+	return v
+}
+
+// isGenericValue reports whether v has no selection set, so the JSON value is decoded
+// into v as a generic JSON value: nil interface{} values, and empty maps and slices of interface{} or maps.
+func isGenericValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v.NumMethod() == 0
+		}
+		return isGenericValue(v.Elem())
+	case reflect.Map:
+		return v.Len() == 0
+	case reflect.Slice:
+		elem := v.Type().Elem()
+		return v.Len() == 0 && (elem.Kind() == reflect.Interface || elem.Kind() == reflect.Map)
+	}
+	return false
+}
+
+// isPolymorphic reports whether values of type t, or its elements, are interface values
+// decoded into the Go types of the __typename of the objects.
+// It's true for interfaces with methods that a registered Go type implements.
+func (d *decoder) isPolymorphic(t reflect.Type) bool {
+	if len(d.types) == 0 {
+		return false
+	}
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Interface || t.NumMethod() == 0 {
+		return false
+	}
+	for _, typ := range d.types {
+		if typ.Implements(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodePolymorphic decodes the JSON value data into v, whose type is polymorphic.
+// The objects are decoded into new values of the Go types of their __typename.
+func (d *decoder) decodePolymorphic(data json.RawMessage, v reflect.Value) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem())) // v = new(T).
+		}
+		return d.decodePolymorphic(data, v.Elem())
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := d.decodePolymorphic(item, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+	var object struct {
+		Typename *string `json:"__typename"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	if object.Typename == nil {
+		return fmt.Errorf("__typename of %v value doesn't exist", v.Type())
+	}
+	typ, ok := d.types[*object.Typename]
+	if !ok || !typ.Implements(v.Type()) {
+		return fmt.Errorf("no Go type of __typename %q implements %v", *object.Typename, v.Type())
+	}
+	value := reflect.New(typ)
+	err := unmarshalGraphQL(&decoder{types: d.types, typenameSelected: true}, data, value.Interface())
+	if err != nil {
+		return err
+	}
+	v.Set(value.Elem())
+	return nil
+}
+
+// mapValueByGraphQLName returns a copy of the value of map m for the key
+// that matches GraphQL name, or invalid reflect.Value if none found.
+// The copy is stored in m at the end of the current JSON object.
+func (d *decoder) mapValueByGraphQLName(m reflect.Value, name string) reflect.Value {
+	if m.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}
+	}
+	for _, key := range sortedMapKeys(m) {
+		if keyHasGraphQLName(key.String(), name) {
+			return d.mapEntryValue(m, key)
+		}
+	}
+	return reflect.Value{}
+}
+
+// mapEntryValue returns an addressable copy of the value of map m for key.
+// The dynamic type of interface values is used, so that their fields can be set.
+func (d *decoder) mapEntryValue(m reflect.Value, key reflect.Value) reflect.Value {
+	value := m.MapIndex(key)
+	t := m.Type().Elem()
+	if t.Kind() == reflect.Interface && !value.IsNil() {
+		value = value.Elem()
+		t = value.Type()
+	}
+	copied := reflect.New(t).Elem()
+	copied.Set(value)
+	d.mapEntries = append(d.mapEntries, mapEntry{m: m, key: key, value: copied, depth: len(d.parseState)})
+	return copied
+}
+
+// storeMapEntries stores the decoded map values of the current JSON object in their maps.
+func (d *decoder) storeMapEntries() {
+	for len(d.mapEntries) > 0 {
+		e := d.mapEntries[len(d.mapEntries)-1]
+		if e.depth != len(d.parseState) {
+			return
+		}
+		e.m.SetMapIndex(e.key, e.value)
+		d.mapEntries = d.mapEntries[:len(d.mapEntries)-1]
+	}
+}
+
+// sortedMapKeys returns the keys of map v with string keys in sorted order.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
 func isOrderedMap(v reflect.Value) bool {
 	if !v.IsValid() {
 		return false
@@ -410,6 +681,10 @@ func keyHasGraphQLName(value, name string) bool {
 	if i := strings.Index(value, ":"); i != -1 {
 		value = value[:i]
 	}
+	if i := strings.Index(value, "@"); i != -1 {
+		// Field directive, e.g. "friends @stream(initialCount: 1)".
+		value = value[:i]
+	}
 	return strings.TrimSpace(value) == name
 }
 
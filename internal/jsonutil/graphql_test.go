@@ -62,6 +62,50 @@ func TestUnmarshalGraphQL_graphqlTag(t *testing.T) {
 	}
 }
 
+func TestUnmarshalGraphQL_graphqlTagDirective(t *testing.T) {
+	type query struct {
+		Friends []struct {
+			Name graphql.String
+		} `graphql:"friends @stream(initialCount: 1)"`
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"friends": [{"name": "Han"}]
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Friends) != 1 || got.Friends[0].Name != "Han" {
+		t.Errorf("got %+v, want: [{Name:Han}]", got.Friends)
+	}
+}
+
+func TestUnmarshalGraphQL_omittedDirectiveFields(t *testing.T) {
+	type query struct {
+		Hero struct {
+			Name    graphql.String
+			Friends []struct {
+				Name graphql.String
+			} `directives:"@include(if: $withFriends)"`
+			Height *graphql.Float `graphql:"height(unit: METER)" directives:"@skip(if: $brief)"`
+		}
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"hero": {
+			"name": "R2-D2"
+		}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Hero.Name = "R2-D2"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal: %v != %v", got, want)
+	}
+}
+
 func TestUnmarshalGraphQL_jsonTag(t *testing.T) {
 	type query struct {
 		Foo graphql.String `json:"baz"`
@@ -151,6 +195,109 @@ func TestUnmarshalGraphQL_orderedMapAlias(t *testing.T) {
 	}
 }
 
+func TestUnmarshalGraphQL_map(t *testing.T) {
+	type Update struct {
+		Name graphql.String `graphql:"name"`
+	}
+	got := map[string]interface{}{
+		"update0:update(name:$name0)": &Update{},
+		"update1:update(name:$name1)": Update{},
+		"stats": map[string]interface{}{
+			"count":    nil,
+			"metadata": map[string]interface{}{},
+			"tags":     []interface{}{},
+		},
+	}
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"update0": {
+			"name": "grihabor"
+		},
+		"update1": {
+			"name": "diman"
+		},
+		"stats": {
+			"count": 2,
+			"metadata": {"source": "test", "scores": [1, 2]},
+			"tags": ["a", {"b": null}]
+		}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"update0:update(name:$name0)": &Update{Name: "grihabor"},
+		"update1:update(name:$name1)": Update{Name: "diman"},
+		"stats": map[string]interface{}{
+			"count": float64(2),
+			"metadata": map[string]interface{}{
+				"source": "test",
+				"scores": []interface{}{float64(1), float64(2)},
+			},
+			"tags": []interface{}{"a", map[string]interface{}{"b": nil}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal: %v != %v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_mapArray(t *testing.T) {
+	type query struct {
+		Users []map[string]interface{}
+	}
+	got := query{
+		Users: []map[string]interface{}{
+			{"login": graphql.String(""), "profile": &struct{ Bio graphql.String }{}},
+		},
+	}
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"users": [
+			{"login": "grihabor", "profile": {"bio": "foo"}},
+			{"login": "diman", "profile": null}
+		]
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Users: []map[string]interface{}{
+			{"login": graphql.String("grihabor"), "profile": &struct{ Bio graphql.String }{Bio: "foo"}},
+			{"login": graphql.String("diman"), "profile": (*struct{ Bio graphql.String })(nil)},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal: %v != %v", got, want)
+	}
+}
+
+func TestUnmarshalGraphQL_genericValue(t *testing.T) {
+	type query struct {
+		Data interface{}
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"data": {"foo": [true, "bar"]}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Data: map[string]interface{}{"foo": []interface{}{true, "bar"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal: %v != %v", got, want)
+	}
+
+	var gotMap map[string]interface{}
+	err = jsonutil.UnmarshalGraphQL([]byte(`{"foo": 1}`), &gotMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"foo": float64(1)}; !reflect.DeepEqual(gotMap, want) {
+		t.Errorf("not equal: %v != %v", gotMap, want)
+	}
+}
+
 func TestUnmarshalGraphQL_array(t *testing.T) {
 	type query struct {
 		Foo []graphql.String
@@ -586,3 +733,151 @@ func TestUnmarshalGraphQL_arrayInsideInlineFragment(t *testing.T) {
 		t.Error("not equal")
 	}
 }
+
+func TestUnmarshalGraphQL_namedFragmentSpreads(t *testing.T) {
+	type UserFields struct {
+		Login string
+		Name  string
+	}
+	type repositoryFields struct {
+		Name  string
+		Owner struct {
+			*UserFields
+		}
+	}
+	type query struct {
+		Viewer struct {
+			UserFields
+		}
+		Repository struct {
+			Fields    repositoryFields `graphql:"..."`
+			CreatedAt string
+		}
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"viewer": {
+			"login": "gopher",
+			"name": "Gopher"
+		},
+		"repository": {
+			"name": "go-graphql-client",
+			"owner": {
+				"login": "hasura",
+				"name": "Hasura"
+			},
+			"createdAt": "2017-05-26"
+		}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Viewer.UserFields = UserFields{Login: "gopher", Name: "Gopher"}
+	want.Repository.Fields.Name = "go-graphql-client"
+	want.Repository.Fields.Owner.UserFields = &UserFields{Login: "hasura", Name: "Hasura"}
+	want.Repository.CreatedAt = "2017-05-26"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal:\ngot: %+v\nwant: %+v", got, want)
+	}
+}
+
+type character interface {
+	characterName() string
+}
+
+type human struct {
+	Name   string
+	Height float64
+}
+
+func (h human) characterName() string { return h.Name }
+
+type droid struct {
+	Name            string
+	PrimaryFunction string
+}
+
+func (d *droid) characterName() string { return d.Name }
+
+func TestUnmarshalGraphQLTypes(t *testing.T) {
+	types := map[string]reflect.Type{
+		"Human": reflect.TypeOf(human{}),
+		"Droid": reflect.TypeOf(&droid{}),
+	}
+	type query struct {
+		Hero    character
+		Friends *[]character
+		Villain character
+	}
+	got := query{Villain: human{Name: "template"}}
+	err := jsonutil.UnmarshalGraphQLTypes([]byte(`{
+		"hero": {
+			"__typename": "Droid",
+			"name": "R2-D2",
+			"primaryFunction": "Astromech"
+		},
+		"friends": [
+			{"__typename": "Human", "name": "Luke Skywalker", "height": 1.72},
+			null
+		],
+		"villain": null
+	}`), &got, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := query{
+		Hero:    &droid{Name: "R2-D2", PrimaryFunction: "Astromech"},
+		Friends: &[]character{human{Name: "Luke Skywalker", Height: 1.72}, nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal:\ngot: %#v\nwant: %#v", got, want)
+	}
+
+	err = jsonutil.UnmarshalGraphQLTypes([]byte(`{"hero": {"__typename": "Wookiee", "name": "Chewbacca"}}`), &got, types)
+	if want := `no Go type of __typename "Wookiee" implements jsonutil_test.character`; err == nil || err.Error() != want {
+		t.Errorf("got error: %v, want: %s", err, want)
+	}
+	err = jsonutil.UnmarshalGraphQLTypes([]byte(`{"hero": {"name": "Chewbacca"}}`), &got, types)
+	if want := "__typename of jsonutil_test.character value doesn't exist"; err == nil || err.Error() != want {
+		t.Errorf("got error: %v, want: %s", err, want)
+	}
+}
+
+func TestUnmarshalGraphQL_skippedFragmentSpread(t *testing.T) {
+	type RepositoryFields struct {
+		Name string
+	}
+	type UserFields struct {
+		Login string
+	}
+	type query struct {
+		Repository struct {
+			*RepositoryFields `directives:"@include(if: $withFields)"`
+			Fields            *RepositoryFields `graphql:"..." directives:"@include(if: $withFields)"`
+			CreatedAt         string
+		}
+		Viewer struct {
+			*UserFields
+			Name string
+		}
+	}
+	var got query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{
+		"repository": {
+			"createdAt": "2017-05-26"
+		},
+		"viewer": {
+			"name": "Gopher"
+		}
+	}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want query
+	want.Repository.CreatedAt = "2017-05-26"
+	want.Viewer.Name = "Gopher"
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("not equal:\ngot: %+v\nwant: %+v", got, want)
+	}
+}
@@ -0,0 +1,304 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// FieldPath resolves a GraphQL response path, such as the "path" of a GraphQL error,
+// against the GraphQL query data structure v and returns the path of the Go field,
+// e.g. ["repository", "issues", 1, "title"] -> "Repository.Issues[1].Title".
+//
+// Fields of inline fragments and embedded structs are included in the Go path.
+// Ordered map entries are written as their index, and map entries as their quoted key.
+// ok is false if the path doesn't exist in v.
+func FieldPath(v interface{}, path []interface{}) (fieldPath string, ok bool) {
+	var b strings.Builder
+	rv := reflect.ValueOf(v)
+	t := reflect.TypeOf(v)
+	for _, elem := range path {
+		t, rv = indirect(t, rv)
+		if t == nil {
+			return b.String(), false
+		}
+		switch elem := elem.(type) {
+		case string:
+			var names []string
+			switch {
+			case t.Kind() == reflect.Struct:
+				names, t, rv = structFieldByGraphQLName(t, rv, elem)
+			case isOrderedMapType(t):
+				names, t, rv = orderedMapEntryByGraphQLName(rv, elem)
+			case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+				names, t, rv = mapEntryByGraphQLName(t, rv, elem)
+			}
+			if t == nil {
+				return b.String(), false
+			}
+			for _, name := range names {
+				if b.Len() > 0 && !strings.HasPrefix(name, "[") {
+					b.WriteString(".")
+				}
+				b.WriteString(name)
+			}
+		default:
+			i, err := pathIndex(elem)
+			if err != nil || (t.Kind() != reflect.Slice && t.Kind() != reflect.Array) {
+				return b.String(), false
+			}
+			fmt.Fprintf(&b, "[%d]", i)
+			t = t.Elem()
+			if rv.IsValid() && i < rv.Len() {
+				rv = rv.Index(i)
+			} else {
+				rv = reflect.Value{}
+			}
+		}
+	}
+	return b.String(), true
+}
+
+// indirect dereferences pointers and interfaces. The dynamic type is used
+// for interfaces, so it returns nil type for nil interface values.
+func indirect(t reflect.Type, v reflect.Value) (reflect.Type, reflect.Value) {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Interface) {
+		if t.Kind() == reflect.Interface {
+			if !v.IsValid() || v.IsNil() {
+				return nil, reflect.Value{}
+			}
+			v = v.Elem()
+			t = v.Type()
+			continue
+		}
+		t = t.Elem()
+		if v.IsValid() && !v.IsNil() {
+			v = v.Elem()
+		} else {
+			v = reflect.Value{}
+		}
+	}
+	return t, v
+}
+
+// structFieldByGraphQLName finds the exported field with GraphQL name in struct type t,
+// searching GraphQL fragments and embedded structs when it isn't a direct field.
+// It returns the Go field names leading to the field, or nil type if none found.
+func structFieldByGraphQLName(t reflect.Type, v reflect.Value, name string) ([]string, reflect.Type, reflect.Value) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// Skip unexported field.
+			continue
+		}
+		if hasGraphQLName(f, name) {
+			return []string{f.Name}, f.Type, fieldSafe(v, i)
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !isGraphQLFragment(f) && !f.Anonymous {
+			continue
+		}
+		ft, fv := indirect(f.Type, fieldSafe(v, i))
+		if ft == nil || ft.Kind() != reflect.Struct {
+			continue
+		}
+		if names, t, v := structFieldByGraphQLName(ft, fv, name); t != nil {
+			return append([]string{f.Name}, names...), t, v
+		}
+	}
+	return nil, nil, reflect.Value{}
+}
+
+// orderedMapEntryByGraphQLName finds the entry with GraphQL name in ordered map v,
+// and returns its index as Go path and the dynamic type of its value.
+func orderedMapEntryByGraphQLName(v reflect.Value, name string) ([]string, reflect.Type, reflect.Value) {
+	if !v.IsValid() {
+		return nil, nil, reflect.Value{}
+	}
+	for i := 0; i < v.Len(); i++ {
+		key, ok := v.Index(i).Index(0).Interface().(string)
+		if !ok {
+			continue
+		}
+		value := v.Index(i).Index(1)
+		if keyHasGraphQLName(key, name) {
+			if value.IsNil() {
+				return nil, nil, reflect.Value{}
+			}
+			value = value.Elem()
+			return []string{fmt.Sprintf("[%d]", i)}, value.Type(), value
+		}
+	}
+	return nil, nil, reflect.Value{}
+}
+
+// mapEntryByGraphQLName finds the entry with GraphQL name in map v with string keys,
+// and returns its quoted key as Go path and the type of its value.
+func mapEntryByGraphQLName(t reflect.Type, v reflect.Value, name string) ([]string, reflect.Type, reflect.Value) {
+	if !v.IsValid() {
+		return nil, nil, reflect.Value{}
+	}
+	for _, key := range sortedMapKeys(v) {
+		if keyHasGraphQLName(key.String(), name) {
+			return []string{fmt.Sprintf("[%q]", key.String())}, t.Elem(), v.MapIndex(key)
+		}
+	}
+	return nil, nil, reflect.Value{}
+}
+
+func isOrderedMapType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice &&
+		t.Elem().Kind() == reflect.Array &&
+		t.Elem().Len() == 2
+}
+
+func fieldSafe(v reflect.Value, i int) reflect.Value {
+	if v.IsValid() {
+		return v.Field(i)
+	}
+	return reflect.Value{}
+}
+
+// pathIndex converts a list index element of a response path to int.
+func pathIndex(elem interface{}) (int, error) {
+	switch elem := elem.(type) {
+	case int:
+		return elem, nil
+	case float64:
+		return int(elem), nil
+	case json.Number:
+		i, err := elem.Int64()
+		return int(i), err
+	default:
+		return 0, fmt.Errorf("invalid path element %v", elem)
+	}
+}
+
+// UnmarshalGraphQLPath is like UnmarshalGraphQL, but it stores the result in the value
+// at the GraphQL response path of v, e.g. the path of a @defer or @stream incremental payload.
+// Nil pointers along the path are allocated, and lists are extended
+// if the index is after the end of the list. The objects of interface values are decoded
+// into the Go types of types, like in UnmarshalGraphQLTypes.
+func UnmarshalGraphQLPath(data []byte, v interface{}, path []interface{}, types map[string]reflect.Type) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cannot decode into non-pointer %T", v)
+	}
+	return unmarshalGraphQLPath(data, rv.Elem(), path, path, types)
+}
+
+// unmarshalGraphQLPath stores the result of data in the value at the rest of the path
+// of target. Map values are decoded into a copy that is stored back in the map.
+func unmarshalGraphQLPath(data []byte, target reflect.Value, path, rest []interface{}, types map[string]reflect.Type) error {
+	for k, elem := range rest {
+		target = allocIndirect(target)
+		switch elem := elem.(type) {
+		case string:
+			var f reflect.Value
+			switch {
+			case target.Kind() == reflect.Struct:
+				f = fieldValueByGraphQLName(target, elem)
+			case isOrderedMap(target):
+				f = orderedMapValueByGraphQLName(target, elem)
+			case target.Kind() == reflect.Map && target.Type().Key().Kind() == reflect.String:
+				return unmarshalGraphQLMapPath(data, target, elem, path, rest[k+1:], types)
+			}
+			if !f.IsValid() {
+				return fmt.Errorf("struct field for %q doesn't exist at path %v", elem, path)
+			}
+			target = f
+		default:
+			i, err := pathIndex(elem)
+			if err != nil {
+				return err
+			}
+			switch target.Kind() {
+			case reflect.Slice:
+				if i >= target.Len() {
+					n := i + 1 - target.Len()
+					target.Set(reflect.AppendSlice(target, reflect.MakeSlice(target.Type(), n, n)))
+				}
+			case reflect.Array:
+			default:
+				return fmt.Errorf("list for index %d doesn't exist at path %v", i, path)
+			}
+			if i >= target.Len() {
+				return fmt.Errorf("index %d out of range at path %v", i, path)
+			}
+			target = target.Index(i)
+		}
+	}
+	target = allocIndirect(target)
+	if !target.CanAddr() {
+		return fmt.Errorf("cannot decode into value of type %v at path %v", target.Type(), path)
+	}
+	return UnmarshalGraphQLTypes(data, target.Addr().Interface(), types)
+}
+
+// unmarshalGraphQLMapPath stores the result of data in the value at the rest of the path
+// of the entry with GraphQL name in map m, like the decoder does for map selections.
+func unmarshalGraphQLMapPath(data []byte, m reflect.Value, name string, path, rest []interface{}, types map[string]reflect.Type) error {
+	for _, key := range sortedMapKeys(m) {
+		if !keyHasGraphQLName(key.String(), name) {
+			continue
+		}
+		value := m.MapIndex(key)
+		t := m.Type().Elem()
+		if t.Kind() == reflect.Interface && !value.IsNil() {
+			value = value.Elem()
+			t = value.Type()
+		}
+		copied := reflect.New(t).Elem()
+		copied.Set(value)
+		if err := unmarshalGraphQLPath(data, copied, path, rest, types); err != nil {
+			return err
+		}
+		m.SetMapIndex(key, copied)
+		return nil
+	}
+	return fmt.Errorf("map entry for %q doesn't exist at path %v", name, path)
+}
+
+// allocIndirect dereferences pointers and interfaces, allocating nil pointers.
+func allocIndirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if v.Kind() == reflect.Interface || !v.CanSet() {
+				return v
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// fieldValueByGraphQLName is like fieldByGraphQLName, but it also searches
+// GraphQL fragments and embedded structs, allocating them if needed.
+func fieldValueByGraphQLName(v reflect.Value, name string) reflect.Value {
+	if f := fieldByGraphQLName(v, name); f.IsValid() {
+		return f
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !isGraphQLFragment(f) && !f.Anonymous {
+			continue
+		}
+		ft, _ := indirect(f.Type, reflect.Value{})
+		if ft == nil || ft.Kind() != reflect.Struct {
+			continue
+		}
+		if _, found, _ := structFieldByGraphQLName(ft, reflect.Value{}, name); found == nil {
+			continue
+		}
+		fv := allocIndirect(v.Field(i))
+		if fv.Kind() == reflect.Struct {
+			return fieldValueByGraphQLName(fv, name)
+		}
+	}
+	return reflect.Value{}
+}
@@ -0,0 +1,185 @@
+package jsonutil_test
+
+import (
+	"reflect"
+	"testing"
+
+	graphql "github.com/hasura/go-graphql-client"
+	"github.com/hasura/go-graphql-client/internal/jsonutil"
+)
+
+func TestFieldPath(t *testing.T) {
+	type issue struct {
+		Title graphql.String
+	}
+	type query struct {
+		Repository struct {
+			Issues struct {
+				Nodes []issue
+			} `graphql:"issues(first: 10)"`
+			Owner *struct {
+				Login graphql.String
+			} `graphql:"repoOwner: owner"`
+		}
+		Node struct {
+			Typename graphql.String `graphql:"__typename"`
+			Issue    issue          `graphql:"... on Issue"`
+		} `graphql:"node(id: $id)"`
+	}
+	q := query{}
+	tests := []struct {
+		path []interface{}
+		want string
+		ok   bool
+	}{
+		{
+			path: []interface{}{"repository", "issues", "nodes", float64(1), "title"},
+			want: "Repository.Issues.Nodes[1].Title",
+			ok:   true,
+		},
+		{
+			path: []interface{}{"repository", "repoOwner", "login"},
+			want: "Repository.Owner.Login",
+			ok:   true,
+		},
+		{
+			path: []interface{}{"node", "title"},
+			want: "Node.Issue.Title",
+			ok:   true,
+		},
+		{
+			path: []interface{}{"repository", "unknown"},
+			want: "Repository",
+			ok:   false,
+		},
+		{
+			path: []interface{}{"repository", float64(0)},
+			want: "Repository",
+			ok:   false,
+		},
+	}
+	for _, tc := range tests {
+		got, ok := jsonutil.FieldPath(&q, tc.path)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("path %v: got %q, %v, want: %q, %v", tc.path, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestFieldPath_orderedMap(t *testing.T) {
+	type createUser struct {
+		Login graphql.String
+	}
+	m := [][2]interface{}{
+		{"createUser(login: $login1)", &createUser{}},
+		{"user2: createUser(login: $login2)", &createUser{}},
+	}
+	got, ok := jsonutil.FieldPath(&m, []interface{}{"user2", "login"})
+	if want := "[1].Login"; got != want || !ok {
+		t.Errorf("got %q, %v, want: %q, true", got, ok, want)
+	}
+}
+
+func TestFieldPath_map(t *testing.T) {
+	type createUser struct {
+		Login graphql.String
+	}
+	m := map[string]interface{}{
+		"user1: createUser(login: $login1)": &createUser{},
+		"user2: createUser(login: $login2)": map[string]interface{}{
+			"login": graphql.String(""),
+		},
+	}
+	tests := []struct {
+		path []interface{}
+		want string
+		ok   bool
+	}{
+		{[]interface{}{"user1", "login"}, `["user1: createUser(login: $login1)"].Login`, true},
+		{[]interface{}{"user2", "login"}, `["user2: createUser(login: $login2)"]["login"]`, true},
+		{[]interface{}{"user3", "login"}, "", false},
+	}
+	for _, tc := range tests {
+		got, ok := jsonutil.FieldPath(&m, tc.path)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("path %v: got %q, %v, want: %q, %v", tc.path, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestUnmarshalGraphQLPath(t *testing.T) {
+	type friend struct {
+		Name graphql.String
+	}
+	type query struct {
+		Hero struct {
+			Name    graphql.String
+			Friends []friend
+			Human   struct {
+				Bio graphql.String
+			} `graphql:"... on Human @defer"`
+		}
+	}
+	var q query
+	err := jsonutil.UnmarshalGraphQL([]byte(`{"hero": {"name": "Luke", "friends": [{"name": "Han"}]}}`), &q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jsonutil.UnmarshalGraphQLPath([]byte(`{"bio": "Jedi"}`), &q, []interface{}{"hero"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jsonutil.UnmarshalGraphQLPath([]byte(`{"name": "Leia"}`), &q, []interface{}{"hero", "friends", float64(1)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want query
+	want.Hero.Name = "Luke"
+	want.Hero.Friends = []friend{{"Han"}, {"Leia"}}
+	want.Hero.Human.Bio = "Jedi"
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("got %+v, want: %+v", q, want)
+	}
+
+	err = jsonutil.UnmarshalGraphQLPath([]byte(`{}`), &q, []interface{}{"hero", "unknown"}, nil)
+	if err == nil {
+		t.Error("got nil error, want: non-nil")
+	}
+}
+
+func TestUnmarshalGraphQLPath_map(t *testing.T) {
+	type human struct {
+		Name graphql.String
+		Bio  graphql.String
+	}
+	m := map[string]interface{}{
+		"hero":                 map[string]interface{}{"name": graphql.String(""), "bio": graphql.String("")},
+		"luke: human(id: $id)": human{},
+	}
+	err := jsonutil.UnmarshalGraphQL([]byte(`{"hero": {"name": "R2-D2"}, "luke": {"name": "Luke"}}`), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jsonutil.UnmarshalGraphQLPath([]byte(`{"bio": "Astromech"}`), &m, []interface{}{"hero"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jsonutil.UnmarshalGraphQLPath([]byte(`{"bio": "Jedi"}`), &m, []interface{}{"luke"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"hero":                 map[string]interface{}{"name": graphql.String("R2-D2"), "bio": graphql.String("Astromech")},
+		"luke: human(id: $id)": human{Name: "Luke", Bio: "Jedi"},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("got %+v, want: %+v", m, want)
+	}
+
+	err = jsonutil.UnmarshalGraphQLPath([]byte(`{}`), &m, []interface{}{"unknown"}, nil)
+	if err == nil {
+		t.Error("got nil error, want: non-nil")
+	}
+}
@@ -0,0 +1,45 @@
+package graphql
+
+import (
+	"context"
+	"net/http"
+)
+
+// Request is a GraphQL operation executed by the client, as seen by middleware.
+// Middleware may modify the request before passing it to the next handler.
+type Request struct {
+	// OperationType is "query" or "mutation".
+	OperationType string
+	OperationName string
+	// Query is the query string constructed from the query struct.
+	Query     string
+	Variables map[string]interface{}
+	// Header contains the HTTP headers of this request only, set by RequestHeader options.
+	// It may be nil.
+	Header http.Header
+}
+
+// Handler executes a GraphQL operation and returns its raw response.
+// The returned error is non-nil only if there isn't any response, e.g. because of transport errors.
+// GraphQL errors are in the Errors field of the response.
+type Handler func(ctx context.Context, req *Request) (*Response, error)
+
+// Middleware wraps the execution of every operation, e.g. for logging, metrics,
+// authentication refresh or request IDs. It returns a handler that usually calls next.
+type Middleware func(next Handler) Handler
+
+// WithMiddleware appends middleware around the execution of every Query and Mutate call,
+// their variants and every operation of Batch. The first middleware is the outermost one.
+// Retries of the client's retry policy happen inside the innermost handler.
+func (c *Client) WithMiddleware(middlewares ...Middleware) *Client {
+	c.middlewares = append(c.middlewares, middlewares...)
+	return c
+}
+
+// chain wraps the handler with the middleware of the client.
+func (c *Client) chain(handler Handler) Handler {
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		handler = c.middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,142 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+func TestClient_Query_middleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("X-Request-Id"), "42"; got != want {
+			t.Errorf("got X-Request-Id header: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}, "errors": [{"message": "deprecated field"}]}`)
+	})
+
+	var calls []string
+	logger := func(next graphql.Handler) graphql.Handler {
+		return func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			calls = append(calls, "logger: "+req.OperationType+" "+req.OperationName+" "+req.Query)
+			resp, err := next(ctx, req)
+			if err == nil {
+				calls = append(calls, "logger: "+resp.Errors.Error())
+			}
+			return resp, err
+		}
+	}
+	requestID := func(next graphql.Handler) graphql.Handler {
+		return func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			calls = append(calls, "requestID")
+			if req.Header == nil {
+				req.Header = make(http.Header)
+			}
+			req.Header.Set("X-Request-Id", "42")
+			return next(ctx, req)
+		}
+	}
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithMiddleware(logger, requestID)
+
+	var q struct {
+		User struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	err := client.Query(context.Background(), &q, map[string]interface{}{"id": graphql.ID("1")}, graphql.OperationName("GetUser"))
+	if got, want := err.Error(), "Message: deprecated field, Locations: []"; got != want {
+		t.Errorf("got error: %v, want: %v", got, want)
+	}
+	if got, want := q.User.Name, "Gopher"; got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+
+	want := []string{
+		"logger: query GetUser query GetUser($id:ID!){user(id: $id){name}}",
+		"requestID",
+		"logger: Message: deprecated field, Locations: []",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("got calls: %q, want: %q", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("got call %d: %q, want: %q", i, calls[i], want[i])
+		}
+	}
+}
+
+func TestClient_Batch_middleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("X-Request-Id"), "42"; got != want {
+			t.Errorf("got X-Request-Id header: %q, want: %q", got, want)
+		}
+		var in []struct {
+			OperationName string
+		}
+		if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		if len(in) != 2 || in[0].OperationName != "A" || in[1].OperationName != "C" {
+			t.Errorf("got operations: %+v, want: A and C", in)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `[{"data": {"user": {"name": "A"}}}, {"data": {"user": {"name": "C"}}}]`)
+	})
+
+	var mu sync.Mutex
+	var calls []string
+	middleware := func(next graphql.Handler) graphql.Handler {
+		return func(ctx context.Context, req *graphql.Request) (*graphql.Response, error) {
+			mu.Lock()
+			calls = append(calls, req.OperationName)
+			mu.Unlock()
+			if req.OperationName == "B" {
+				// answered without sending the operation
+				data := json.RawMessage(`{"user": {"name": "cached"}}`)
+				return &graphql.Response{Data: &data}, nil
+			}
+			req.Header = http.Header{"X-Request-Id": []string{"42"}}
+			return next(ctx, req)
+		}
+	}
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithMiddleware(middleware)
+
+	type userQuery struct {
+		User struct {
+			Name string
+		}
+	}
+	var a, b, c userQuery
+	operations := []*graphql.BatchOperation{
+		graphql.BatchQuery(&a, nil, graphql.OperationName("A")),
+		graphql.BatchQuery(&b, nil, graphql.OperationName("B")),
+		graphql.BatchQuery(&c, nil, graphql.OperationName("C")),
+	}
+	err := client.Batch(context.Background(), operations...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, operation := range operations {
+		if operation.Err != nil {
+			t.Errorf("got operation %d error: %v, want: nil", i, operation.Err)
+		}
+	}
+	if got, want := a.User.Name+","+b.User.Name+","+c.User.Name, "A,cached,C"; got != want {
+		t.Errorf("got names: %s, want: %s", got, want)
+	}
+	sort.Strings(calls)
+	if got, want := strings.Join(calls, ","), "A,B,C"; got != want {
+		t.Errorf("got middleware calls: %s, want: %s", got, want)
+	}
+}
@@ -1,5 +1,10 @@
 package graphql
 
+import (
+	"net/http"
+	"strings"
+)
+
 // OptionType represents the logic of graphql query construction
 type OptionType string
 
@@ -7,13 +12,21 @@ const (
 	// optionTypeOperationName is private because it's option is built-in and unique
 	optionTypeOperationName      OptionType = "operation_name"
 	OptionTypeOperationDirective OptionType = "operation_directive"
+	// optionTypeRequestHeader is private because it isn't rendered into the query string
+	optionTypeRequestHeader OptionType = "request_header"
+	// optionTypeBindExtensions is private because it isn't rendered into the query string
+	optionTypeBindExtensions OptionType = "bind_extensions"
+	// optionTypeRequestMethod is private because it isn't rendered into the query string
+	optionTypeRequestMethod OptionType = "request_method"
+	// optionTypeBindAttempts is private because it isn't rendered into the query string
+	optionTypeBindAttempts OptionType = "bind_attempts"
 )
 
 // Option abstracts an extra render interface for the query string
 // They are optional parts. By default GraphQL queries can request data without them
 type Option interface {
 	// Type returns the supported type of the renderer
-	// available types: operation_name and operation_directive
+	// available types: operation_name, operation_directive, request_header, bind_extensions, request_method and bind_attempts
 	Type() OptionType
 	// String returns the query component string
 	String() string
@@ -36,3 +49,113 @@ func (ono operationNameOption) String() string {
 func OperationName(name string) Option {
 	return operationNameOption{name}
 }
+
+// operationDirectiveOption represents a directive of the operation
+type operationDirectiveOption struct {
+	directive string
+}
+
+func (odo operationDirectiveOption) Type() OptionType {
+	return OptionTypeOperationDirective
+}
+
+func (odo operationDirectiveOption) String() string {
+	return odo.directive
+}
+
+// OperationDirective creates an option that renders a directive of the operation,
+// e.g. OperationDirective("@cached(ttl: 120)") for Hasura's response caching.
+// The variables referenced by the directive must be defined, like the variables of struct tags
+func OperationDirective(directive string) Option {
+	return operationDirectiveOption{directive}
+}
+
+// requestHeaderOption adds an HTTP header to a single request
+type requestHeaderOption struct {
+	key   string
+	value string
+}
+
+func (rho requestHeaderOption) Type() OptionType {
+	return optionTypeRequestHeader
+}
+
+// String returns the header line. The header isn't part of the query string
+func (rho requestHeaderOption) String() string {
+	return rho.key + ": " + rho.value
+}
+
+// RequestHeader creates an option that sets the HTTP header key to value for a single Query or Mutate call.
+// It overrides the same header set by the client's request modifiers.
+// Multiple RequestHeader options with the same key add multiple values
+func RequestHeader(key, value string) Option {
+	return requestHeaderOption{
+		key:   http.CanonicalHeaderKey(key),
+		value: value,
+	}
+}
+
+// bindExtensionsOption decodes the response extensions into a caller-provided value
+type bindExtensionsOption struct {
+	value interface{}
+}
+
+func (beo bindExtensionsOption) Type() OptionType {
+	return optionTypeBindExtensions
+}
+
+// String returns an empty string. The extensions value isn't part of the query string
+func (beo bindExtensionsOption) String() string {
+	return ""
+}
+
+// BindExtensions creates an option that decodes the "extensions" object of the response into v,
+// e.g. tracing data, query cost or server timings. v should be a pointer.
+// v isn't modified if the response doesn't have extensions.
+//
+// In subscriptions, v is updated with the extensions of each data message
+// right before the handler is called. Handler calls of the subscription are serialized.
+func BindExtensions(v interface{}) Option {
+	return bindExtensionsOption{v}
+}
+
+// requestMethodOption overrides the HTTP method of a single query
+type requestMethodOption struct {
+	method string
+}
+
+func (rmo requestMethodOption) Type() OptionType {
+	return optionTypeRequestMethod
+}
+
+// String returns the HTTP method. The method isn't part of the query string
+func (rmo requestMethodOption) String() string {
+	return rmo.method
+}
+
+// RequestMethod creates an option that overrides the client's HTTP method of a single query,
+// http.MethodGet or http.MethodPost. GET queries that would exceed the client's
+// maximum URL length are still sent with POST. Mutations are always sent with POST
+func RequestMethod(method string) Option {
+	return requestMethodOption{strings.ToUpper(method)}
+}
+
+// bindAttemptsOption stores the number of attempts of an operation
+type bindAttemptsOption struct {
+	attempts *int
+}
+
+func (bao bindAttemptsOption) Type() OptionType {
+	return optionTypeBindAttempts
+}
+
+// String returns an empty string. The number of attempts isn't part of the query string
+func (bao bindAttemptsOption) String() string {
+	return ""
+}
+
+// BindAttempts creates an option that stores the number of HTTP attempts of a Query or Mutate call
+// into n, including retries of the client's retry policy
+func BindAttempts(n *int) Option {
+	return bindAttemptsOption{n}
+}
@@ -0,0 +1,111 @@
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Automatic persisted queries follow Apollo's specification
+// https://github.com/apollographql/apollo-link-persisted-queries#apollo-engine
+
+// Error messages and codes of the server when it can't execute the hashed query.
+const (
+	ErrorPersistedQueryNotFound         = "PersistedQueryNotFound"
+	ErrorPersistedQueryNotSupported     = "PersistedQueryNotSupported"
+	ErrorCodePersistedQueryNotFound     = "PERSISTED_QUERY_NOT_FOUND"
+	ErrorCodePersistedQueryNotSupported = "PERSISTED_QUERY_NOT_SUPPORTED"
+)
+
+// persistedQueries holds the automatic persisted queries settings and
+// the sha256 hashes of query strings, cached per query struct type.
+type persistedQueries struct {
+	// useGET sends hashed queries with HTTP GET, so they can be cached by HTTP caches
+	useGET bool
+	// disabled is set when the server doesn't support persisted queries
+	disabled int32
+	hashes   sync.Map // map[persistedQueryKey]persistedQueryHash
+}
+
+type persistedQueryKey struct {
+	t  reflect.Type
+	op operationType
+}
+
+type persistedQueryHash struct {
+	query string
+	hash  string
+}
+
+func (pq *persistedQueries) enabled() bool {
+	return atomic.LoadInt32(&pq.disabled) == 0
+}
+
+// hash returns the sha256 hash of query constructed from v.
+// The query string depends on the variables, options and ordered map values as well as on the type of v,
+// so the cached hash is used only if its query string is equal.
+func (pq *persistedQueries) hash(op operationType, v interface{}, query string) string {
+	key := persistedQueryKey{reflect.TypeOf(v), op}
+	if cached, ok := pq.hashes.Load(key); ok && cached.(persistedQueryHash).query == query {
+		return cached.(persistedQueryHash).hash
+	}
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	pq.hashes.Store(key, persistedQueryHash{query: query, hash: hash})
+	return hash
+}
+
+// WithAutomaticPersistedQueries enables automatic persisted queries.
+// The client sends only the sha256 hash of the query string in the extensions.persistedQuery field.
+// If the server doesn't know the hash yet, the request is sent again with the full query string,
+// so the server can register it.
+// If useGET is true, hashed queries are sent with HTTP GET, so they can be cached by CDNs
+// and HTTP caches. Mutations are always sent with POST.
+// Otherwise, the method of queries is chosen like for queries without hash, see WithGETQueries.
+func (c *Client) WithAutomaticPersistedQueries(useGET bool) *Client {
+	c.persistedQueries = &persistedQueries{
+		useGET: useGET,
+	}
+	return c
+}
+
+// requestPersisted sends the hash of the query in the payload, and falls back
+// to the full query string if the server can't find the hash.
+func (c *Client) requestPersisted(ctx context.Context, op operationType, v interface{}, in *requestPayload, method string, headers http.Header) (*Response, error) {
+	query := in.Query
+	hashed := &requestPayload{
+		Variables:     in.Variables,
+		OperationName: in.OperationName,
+		Extensions: map[string]interface{}{
+			"persistedQuery": map[string]interface{}{
+				"version":    1,
+				"sha256Hash": c.persistedQueries.hash(op, v, query),
+			},
+		},
+	}
+
+	hashedMethod := method
+	if c.persistedQueries.useGET && op == queryOperation {
+		hashedMethod = http.MethodGet
+	}
+	out, err := c.send(ctx, hashedMethod, hashed, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case out.Errors.hasMessageOrCode(ErrorPersistedQueryNotSupported, ErrorCodePersistedQueryNotSupported):
+		// don't send hashes anymore if the server doesn't support them
+		atomic.StoreInt32(&c.persistedQueries.disabled, 1)
+		return c.send(ctx, method, in, headers)
+	case out.Errors.hasMessageOrCode(ErrorPersistedQueryNotFound, ErrorCodePersistedQueryNotFound):
+		// register the query with its hash
+		hashed.Query = query
+		return c.send(ctx, method, hashed, headers)
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package graphql_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+func TestClient_Query_automaticPersistedQueries(t *testing.T) {
+	const query = `query ($id:ID!){user(id: $id){name}}`
+	sum := sha256.Sum256([]byte(query))
+	wantHash := hex.EncodeToString(sum[:])
+
+	registered := map[string]string{}
+	var requests []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		var in struct {
+			Query      string
+			Variables  map[string]interface{}
+			Extensions struct {
+				PersistedQuery struct {
+					Version    int
+					Sha256Hash string
+				}
+			}
+		}
+		if req.Method == http.MethodGet {
+			params := req.URL.Query()
+			in.Query = params.Get("query")
+			if err := json.Unmarshal([]byte(params.Get("variables")), &in.Variables); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(params.Get("extensions")), &in.Extensions); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, req.Method+" "+in.Query)
+
+		hash := in.Extensions.PersistedQuery.Sha256Hash
+		if got := hash; got != wantHash {
+			t.Errorf("got hash: %q, want: %q", got, wantHash)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if in.Query == "" {
+			if _, ok := registered[hash]; !ok {
+				mustWrite(w, `{"errors": [{"message": "PersistedQueryNotFound", "extensions": {"code": "PERSISTED_QUERY_NOT_FOUND"}}]}`)
+				return
+			}
+		} else {
+			registered[hash] = in.Query
+		}
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithAutomaticPersistedQueries(true)
+
+	var q struct {
+		User struct {
+			Name string
+		} `graphql:"user(id: $id)"`
+	}
+	variables := map[string]interface{}{
+		"id": graphql.ID("1"),
+	}
+	for i := 0; i < 2; i++ {
+		q.User.Name = ""
+		err := client.Query(context.Background(), &q, variables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := q.User.Name, "Gopher"; got != want {
+			t.Errorf("got q.User.Name: %q, want: %q", got, want)
+		}
+	}
+
+	want := []string{
+		"GET ",
+		"POST " + query,
+		"GET ",
+	}
+	if len(requests) != len(want) {
+		t.Fatalf("got requests: %q, want: %q", requests, want)
+	}
+	for i := range want {
+		if requests[i] != want[i] {
+			t.Errorf("got request %d: %q, want: %q", i, requests[i], want[i])
+		}
+	}
+}
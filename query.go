@@ -3,11 +3,14 @@ package graphql
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/hasura/go-graphql-client/ident"
 )
@@ -15,6 +18,20 @@ import (
 type constructOptionsOutput struct {
 	operationName       string
 	operationDirectives []string
+	headers             http.Header
+	extensions          interface{}
+	method              string
+	attempts            *int
+}
+
+// operationTypes are the Go types of an operation, configured on the client or read from variable structs.
+type operationTypes struct {
+	// variables are the Go types of the variables whose types differ from the types of their values
+	variables map[string]reflect.Type
+	// names are the GraphQL type names of Go types in variable declarations
+	names map[reflect.Type]string
+	// registry maps the __typename of objects to the Go types of interface fields
+	registry *typeRegistry
 }
 
 func (coo constructOptionsOutput) OperationDirectivesString() string {
@@ -34,6 +51,33 @@ func constructOptions(options []Option) (*constructOptionsOutput, error) {
 			output.operationName = option.String()
 		case OptionTypeOperationDirective:
 			output.operationDirectives = append(output.operationDirectives, option.String())
+		case optionTypeRequestHeader:
+			rho, ok := option.(requestHeaderOption)
+			if !ok {
+				return nil, fmt.Errorf("invalid request header option: %s", option.String())
+			}
+			if output.headers == nil {
+				output.headers = make(http.Header)
+			}
+			output.headers.Add(rho.key, rho.value)
+		case optionTypeBindExtensions:
+			beo, ok := option.(bindExtensionsOption)
+			if !ok {
+				return nil, fmt.Errorf("invalid bind extensions option: %T", option)
+			}
+			output.extensions = beo.value
+		case optionTypeRequestMethod:
+			method := option.String()
+			if method != http.MethodGet && method != http.MethodPost {
+				return nil, fmt.Errorf("invalid request method: %s, must be GET or POST", method)
+			}
+			output.method = method
+		case optionTypeBindAttempts:
+			bao, ok := option.(bindAttemptsOption)
+			if !ok {
+				return nil, fmt.Errorf("invalid bind attempts option: %T", option)
+			}
+			output.attempts = bao.attempts
 		default:
 			return nil, fmt.Errorf("invalid query option type: %s", option.Type())
 		}
@@ -43,60 +87,181 @@ func constructOptions(options []Option) (*constructOptionsOutput, error) {
 }
 
 func constructQuery(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
-	query := query(v)
+	return constructOperation("query", v, variables, operationTypes{}, options...)
+}
+
+func constructMutation(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
+	return constructOperation("mutation", v, variables, operationTypes{}, options...)
+}
+
+func constructSubscription(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
+	return constructOperation("subscription", v, variables, operationTypes{}, options...)
+}
+
+// queryCache caches the selection sets built from query struct types, so that the reflection of writeQuery
+// runs once per type. The operation names, directives and variable declarations of the operations
+// aren't part of the cache, so its size is bounded by the number of query struct types.
+// Selection sets built with a type registry are cached in the registry, see typeRegistry.
+var queryCache sync.Map // map[reflect.Type]*builtQuery
+
+// builtQuery is the selection set built from a query struct type, with its fragment definitions
+// and the variables referenced by its fields.
+type builtQuery struct {
+	selection string
+	fragments string
+	variables []variableReference
+}
 
+// valueDependentTypes caches whether the query of a type depends on its value.
+var valueDependentTypes sync.Map // map[reflect.Type]bool
+
+// constructOperation constructs the query string of the operation with the keyword operation,
+// e.g. "query", derived from v. types are the Go types of the variables and the interface fields.
+func constructOperation(operation string, v interface{}, variables map[string]interface{}, types operationTypes, options ...Option) (string, error) {
 	optionsOutput, err := constructOptions(options)
 	if err != nil {
 		return "", err
 	}
 
+	var arguments string
 	if len(variables) > 0 {
-		return fmt.Sprintf("query %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), nil
+		arguments, err = queryArguments(variables, types.variables, types.names)
+		if err != nil {
+			return "", err
+		}
 	}
-
-	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return query, nil
+	q, err := cachedQuery(v, types.registry)
+	if err != nil {
+		return "", err
 	}
-
-	return fmt.Sprintf("query %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), nil
+	if err := checkVariables(q.variables, optionsOutput.operationDirectives, variables); err != nil {
+		return "", err
+	}
+	directives := optionsOutput.OperationDirectivesString()
+	query := q.selection
+	switch {
+	case len(variables) > 0:
+		query = fmt.Sprintf("%s %s(%s)%s%s", operation, optionsOutput.operationName, arguments, directives, query)
+	case optionsOutput.operationName != "" || len(optionsOutput.operationDirectives) > 0:
+		query = fmt.Sprintf("%s %s%s%s", operation, optionsOutput.operationName, directives, query)
+	case operation != "query":
+		query = operation + query
+	}
+	return query + q.fragments, nil
 }
 
-func constructMutation(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
-	query := query(v)
-	optionsOutput, err := constructOptions(options)
-	if err != nil {
-		return "", err
+// cachedQuery returns the selection set built from v, from the cache if the selection set
+// doesn't depend on the value of v.
+func cachedQuery(v interface{}, registry *typeRegistry) (*builtQuery, error) {
+	t := reflect.TypeOf(v)
+	cache := &queryCache
+	if registry != nil {
+		cache = &registry.queries
 	}
-	if len(variables) > 0 {
-		return fmt.Sprintf("mutation %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), nil
+	cacheable := !isValueDependent(t)
+	if cacheable {
+		if cached, ok := cache.Load(t); ok {
+			return cached.(*builtQuery), nil
+		}
 	}
 
-	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return "mutation" + query, nil
+	b, err := buildQuery(v, registry)
+	if err != nil {
+		return nil, err
 	}
+	q := &builtQuery{selection: b.buf.String(), variables: b.variables}
+	for _, fragment := range b.fragments {
+		q.fragments += fmt.Sprintf("fragment %s on %s%s", fragment.name, fragment.typeCondition, fragment.selection)
+	}
+	if cacheable {
+		cache.Store(t, q)
+	}
+	return q, nil
+}
 
-	return fmt.Sprintf("mutation %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), nil
+// checkVariables checks that the variables referenced by the fields and the operation directives are defined,
+// and that the defined variables are used.
+func checkVariables(references []variableReference, directives []string, variables map[string]interface{}) error {
+	used := make(map[string]bool, len(variables))
+	for _, ref := range references {
+		if _, ok := variables[ref.name]; !ok {
+			return queryFieldError(ref.path, fmt.Errorf("variable $%s is not defined", ref.name))
+		}
+		used[ref.name] = true
+	}
+	for _, directive := range directives {
+		parsed, err := parseGraphQLTag(directive)
+		if err != nil {
+			return fmt.Errorf("invalid operation directive: %w", err)
+		}
+		for _, name := range parsed.variables {
+			if _, ok := variables[name]; !ok {
+				return fmt.Errorf("invalid operation directive %s: variable $%s is not defined", directive, name)
+			}
+			used[name] = true
+		}
+	}
+	if len(used) == len(variables) {
+		return nil
+	}
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		if !used[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return fmt.Errorf("invalid variable %s: not used by the query", names[0])
 }
 
-func constructSubscription(v interface{}, variables map[string]interface{}, options ...Option) (string, error) {
-	query := query(v)
-	optionsOutput, err := constructOptions(options)
-	if err != nil {
-		return "", err
+// isValueDependent reports whether the query of type t depends on the value, so it can't be cached.
+// It's true for types with ordered map [][2]interface{} or map selections.
+func isValueDependent(t reflect.Type) bool {
+	if t == nil {
+		return true
 	}
-	if len(variables) > 0 {
-		return fmt.Sprintf("subscription %s(%s)%s%s", optionsOutput.operationName, queryArguments(variables), optionsOutput.OperationDirectivesString(), query), nil
+	if cached, ok := valueDependentTypes.Load(t); ok {
+		return cached.(bool)
 	}
-	if optionsOutput.operationName == "" && len(optionsOutput.operationDirectives) == 0 {
-		return "subscription" + query, nil
+	dependent := hasValueDependentSelection(t, map[reflect.Type]bool{})
+	valueDependentTypes.Store(t, dependent)
+	return dependent
+}
+
+func hasValueDependentSelection(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
 	}
-	return fmt.Sprintf("subscription %s%s%s", optionsOutput.operationName, optionsOutput.OperationDirectivesString(), query), nil
+	visited[t] = true
+	switch t.Kind() {
+	case reflect.Ptr:
+		return hasValueDependentSelection(t.Elem(), visited)
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Array {
+			return true
+		}
+		return hasValueDependentSelection(t.Elem(), visited)
+	case reflect.Struct:
+		if reflect.PtrTo(t).Implements(jsonUnmarshaler) {
+			return false
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if hasValueDependentSelection(t.Field(i).Type, visited) {
+				return true
+			}
+		}
+	case reflect.Map:
+		return true
+	}
+	return false
 }
 
 // queryArguments constructs a minified arguments string for variables.
+// typeNames maps Go types to GraphQL type names, overriding the names of the types.
 //
 // E.g., map[string]interface{}{"a": Int(123), "b": NewBoolean(true)} -> "$a:Int!$b:Boolean".
-func queryArguments(variables map[string]interface{}) string {
+// types are the Go types of the variables whose types differ from the types of their values.
+func queryArguments(variables map[string]interface{}, types map[string]reflect.Type, typeNames map[reflect.Type]string) (string, error) {
 	// Sort keys in order to produce deterministic output for testing purposes.
 	// TODO: If tests can be made to work with non-deterministic output, then no need to sort.
 	keys := make([]string, 0, len(variables))
@@ -110,33 +275,138 @@ func queryArguments(variables map[string]interface{}) string {
 		io.WriteString(&buf, "$")
 		io.WriteString(&buf, k)
 		io.WriteString(&buf, ":")
-		writeArgumentType(&buf, reflect.TypeOf(variables[k]), true)
+		t, ok := types[k]
+		if !ok || declaredByValue(t, typeNames) {
+			t = reflect.TypeOf(variables[k])
+			if ok && t == nil {
+				return "", fmt.Errorf("invalid variable %s: the GraphQL type of interface %v is declared by its value, which is nil", k, types[k])
+			}
+		}
+		if err := writeArgumentType(&buf, t, true, typeNames); err != nil {
+			return "", fmt.Errorf("invalid variable %s: %w", k, err)
+		}
 		// Don't insert a comma here.
 		// Commas in GraphQL are insignificant, and we want minified output.
 		// See https://facebook.github.io/graphql/October2016/#sec-Insignificant-Commas.
 	}
-	return buf.String()
+	return buf.String(), nil
+}
+
+// variablesMap converts the variables of an operation to a map from the variable names to their values.
+// variables is nil, a map with string keys, or a struct or a pointer to struct.
+// The variable names of struct fields are their graphql tags, or their lowerCamelCase names,
+// and the Go types of the fields declare the GraphQL types. The types of interface fields,
+// e.g. ID, are lost in the map, so they are returned in types.
+//
+// E.g., struct{ID ID; First *Int `graphql:"limit"`} -> map[string]interface{}{"id": ID(...), "limit": (*Int)(...)}.
+func variablesMap(variables interface{}) (vars map[string]interface{}, types map[string]reflect.Type, err error) {
+	switch variables := variables.(type) {
+	case nil:
+		return nil, nil, nil
+	case map[string]interface{}:
+		return variables, nil, nil
+	}
+	v := reflect.ValueOf(variables)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		vars = make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			vars[key.String()] = v.MapIndex(key).Interface()
+		}
+		return vars, nil, nil
+	case v.Kind() == reflect.Struct:
+		vars = make(map[string]interface{}, v.NumField())
+		types = make(map[string]reflect.Type)
+		if err := addStructVariables(vars, types, v); err != nil {
+			return nil, nil, err
+		}
+		return vars, types, nil
+	}
+	return nil, nil, fmt.Errorf("invalid variables type %T, must be a map with string keys or a struct", variables)
+}
+
+// addStructVariables adds the exported fields of struct v to the variables m, and the types
+// of interface fields to types, including the fields of embedded structs without graphql tags.
+func addStructVariables(m map[string]interface{}, types map[string]reflect.Type, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := f.Tag.Lookup("graphql")
+		switch {
+		case name == "-":
+			continue
+		case !ok && f.Anonymous && f.Type.Kind() == reflect.Struct:
+			if err := addStructVariables(m, types, v.Field(i)); err != nil {
+				return err
+			}
+			continue
+		case f.PkgPath != "":
+			// Skip unexported field.
+			continue
+		case !ok:
+			name = ident.ParseMixedCaps(f.Name).ToLowerCamelCase()
+		}
+		for j := 0; j < len(name); j++ {
+			if !isNameChar(name[j]) {
+				return fmt.Errorf("invalid variables field %s: invalid variable name %q", f.Name, name)
+			}
+		}
+		if _, exists := m[name]; exists {
+			return fmt.Errorf("invalid variables field %s: duplicate variable %s", f.Name, name)
+		}
+		m[name] = v.Field(i).Interface()
+		if f.Type.Kind() == reflect.Interface {
+			types[name] = f.Type
+		}
+	}
+	return nil
 }
 
 // writeArgumentType writes a minified GraphQL type for t to w.
 // value indicates whether t is a value (required) type or pointer (optional) type.
 // If value is true, then "!" is written at the end of t.
-func writeArgumentType(w io.Writer, t reflect.Type, value bool) {
+func writeArgumentType(w io.Writer, t reflect.Type, value bool, typeNames map[reflect.Type]string) error {
+	if t == nil {
+		return errors.New("the type of a nil value is unknown, use a typed nil pointer instead")
+	}
 	if t.Kind() == reflect.Ptr {
 		// Pointer is an optional type, so no "!" at the end of the pointer's underlying type.
-		writeArgumentType(w, t.Elem(), false)
-		return
+		return writeArgumentType(w, t.Elem(), false, typeNames)
+	}
+
+	if name, ok := graphQLTypeName(t, typeNames); ok {
+		// Named type declared by the client or the type. E.g., "uuid".
+		io.WriteString(w, name)
+		if value {
+			io.WriteString(w, "!")
+		}
+		return nil
 	}
 
 	switch t.Kind() {
 	case reflect.Slice, reflect.Array:
 		// List. E.g., "[Int]".
 		io.WriteString(w, "[")
-		writeArgumentType(w, t.Elem(), true)
+		if err := writeArgumentType(w, t.Elem(), true, typeNames); err != nil {
+			return err
+		}
 		io.WriteString(w, "]")
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Complex64, reflect.Complex128:
+		return fmt.Errorf("type %v is not supported", t)
+	case reflect.Interface:
+		if t.Implements(graphQLTypeType) {
+			return fmt.Errorf("the GraphQL type of interface %v is declared by its values, use a concrete type or WithGraphQLType", t)
+		}
+		fallthrough
 	default:
 		// Named type. E.g., "Int".
 		name := t.Name()
+		if name == "" {
+			return fmt.Errorf("type %v has no name to use as the GraphQL type", t)
+		}
 		if name == "string" { // HACK: Workaround for https://github.com/shurcooL/githubv4/issues/12.
 			name = "ID"
 		}
@@ -147,76 +417,406 @@ func writeArgumentType(w io.Writer, t reflect.Type, value bool) {
 		// Value is a required type, so add "!" to the end.
 		io.WriteString(w, "!")
 	}
+	return nil
+}
+
+// declaredByValue reports whether the GraphQL type of a variable of interface type t
+// is declared by the GraphQLType method of its value, because typeNames doesn't declare t.
+func declaredByValue(t reflect.Type, typeNames map[reflect.Type]string) bool {
+	if t.Kind() != reflect.Interface || !t.Implements(graphQLTypeType) {
+		return false
+	}
+	_, ok := typeNames[t]
+	return !ok
+}
+
+// graphQLTypeName returns the GraphQL type name of t declared in typeNames,
+// or by the GraphQLType method of t. The GraphQLType method of interfaces is declared by their values.
+func graphQLTypeName(t reflect.Type, typeNames map[reflect.Type]string) (string, bool) {
+	if name, ok := typeNames[t]; ok {
+		return name, true
+	}
+	if t.Kind() == reflect.Interface {
+		return "", false
+	}
+	if t.Implements(graphQLTypeType) {
+		return reflect.Zero(t).Interface().(GraphQLType).GraphQLType(), true
+	}
+	if reflect.PtrTo(t).Implements(graphQLTypeType) {
+		return reflect.New(t).Interface().(GraphQLType).GraphQLType(), true
+	}
+	return "", false
 }
 
+var graphQLTypeType = reflect.TypeOf((*GraphQLType)(nil)).Elem()
+
 // query uses writeQuery to recursively construct
 // a minified query string from the provided struct v.
 //
 // E.g., struct{Foo Int, BarBaz *Boolean} -> "{foo,barBaz}".
-func query(v interface{}) string {
-	var buf bytes.Buffer
-	writeQuery(&buf, reflect.TypeOf(v), reflect.ValueOf(v), false)
-	return buf.String()
+func query(v interface{}) (string, error) {
+	b, err := buildQuery(v, nil)
+	if err != nil {
+		return "", err
+	}
+	return b.buf.String(), nil
+}
+
+// NamedFragment is implemented by query struct types that are named fragments.
+// The fields of the struct are selected by a fragment definition, e.g.
+// "fragment UserFields on User{login,name}", that is written once after the operation,
+// and each use of the struct in the query is a spread of the fragment, e.g. "...UserFields".
+type NamedFragment interface {
+	// GraphQLFragment returns the fragment name, and the type condition of the fragment.
+	GraphQLFragment() (name string, typeCondition string)
+}
+
+// queryBuilder constructs a minified query string,
+// and records the variables referenced by the fields of the query.
+type queryBuilder struct {
+	buf *bytes.Buffer
+	// variables are the variable references in the order of the fields
+	variables []variableReference
+	// fragments are the named fragment definitions in the order of their first spreads
+	fragments []*fragmentDefinition
+	// types are the Go types of the interfaces by __typename
+	types *typeRegistry
+	// interfaces are the interface types whose selection sets are being written
+	interfaces []reflect.Type
+}
+
+// fragmentDefinition is the definition of a named fragment of the document.
+type fragmentDefinition struct {
+	name          string
+	typeCondition string
+	t             reflect.Type
+	// selection is the minified selection set, e.g. "{login,name}"
+	selection string
+}
+
+// variableReference is a variable referenced by the graphql tag of the Go field at path.
+type variableReference struct {
+	name string
+	path string
+}
+
+// buildQuery constructs the query string from the provided struct v.
+// The selection sets of interfaces are derived from the Go types of types.
+func buildQuery(v interface{}, types *typeRegistry) (*queryBuilder, error) {
+	if v == nil {
+		return nil, errors.New("invalid query: nil")
+	}
+	b := &queryBuilder{buf: &bytes.Buffer{}, types: types}
+	if err := b.writeQuery(reflect.TypeOf(v), reflect.ValueOf(v), "", false); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// writeTag writes the graphql tag, or the map key, of the field at path,
+// and records its variable references.
+func (b *queryBuilder) writeTag(tag string, path string) error {
+	parsed, err := parseGraphQLTag(tag)
+	if err != nil {
+		return queryFieldError(path, err)
+	}
+	for _, name := range parsed.variables {
+		b.variables = append(b.variables, variableReference{name: name, path: path})
+	}
+	b.buf.WriteString(tag)
+	return nil
 }
 
-// writeQuery writes a minified query for t to w.
+// writeDirectives writes the directives tag of the field at path,
+// e.g. `@include(if: $withFriends)`, and records its variable references.
+func (b *queryBuilder) writeDirectives(directives string, path string) error {
+	directives = strings.TrimSpace(directives)
+	if !strings.HasPrefix(directives, "@") {
+		return queryFieldError(path, fmt.Errorf("malformed directives tag %q: expected a directive", directives))
+	}
+	b.buf.WriteString(" ")
+	return b.writeTag(directives, path)
+}
+
+// writeQuery writes a minified query for t to b.
+// path is the Go field path of t, e.g. "Repository.Issues[0]", used in errors.
 // If inline is true, the struct fields of t are inlined into parent struct.
-func writeQuery(w io.Writer, t reflect.Type, v reflect.Value, inline bool) {
+func (b *queryBuilder) writeQuery(t reflect.Type, v reflect.Value, path string, inline bool) error {
 	switch t.Kind() {
 	case reflect.Ptr:
-		writeQuery(w, t.Elem(), ElemSafe(v), false)
+		return b.writeQuery(t.Elem(), ElemSafe(v), path, false)
 	case reflect.Struct:
 		// If the type implements json.Unmarshaler, it's a scalar. Don't expand it.
 		if reflect.PtrTo(t).Implements(jsonUnmarshaler) {
-			return
+			return nil
 		}
-		if !inline {
-			io.WriteString(w, "{")
-		}
-		for i := 0; i < t.NumField(); i++ {
-			if i != 0 {
-				io.WriteString(w, ",")
-			}
-			f := t.Field(i)
-			value, ok := f.Tag.Lookup("graphql")
-			inlineField := f.Anonymous && !ok
-			if !inlineField {
-				if ok {
-					io.WriteString(w, value)
-				} else {
-					io.WriteString(w, ident.ParseMixedCaps(f.Name).ToLowerCamelCase())
-				}
-			}
-			writeQuery(w, f.Type, FieldSafe(v, i), inlineField)
-		}
-		if !inline {
-			io.WriteString(w, "}")
+		if name, typeCondition, ok := namedFragment(t); ok {
+			return b.writeFragmentSpread(t, v, path, inline, name, typeCondition)
 		}
+		return b.writeStruct(t, v, path, inline)
+	case reflect.Interface:
+		return b.writeInterface(t, path)
 	case reflect.Slice:
 		if t.Elem().Kind() != reflect.Array {
-			writeQuery(w, t.Elem(), IndexSafe(v, 0), false)
-			return
+			return b.writeQuery(t.Elem(), IndexSafe(v, 0), path, false)
 		}
 		// handle [][2]interface{} like an ordered map
 		if t.Elem().Len() != 2 {
-			err := fmt.Errorf("only arrays of len 2 are supported, got %v", t.Elem())
-			panic(err.Error())
+			return queryFieldError(path, fmt.Errorf("only arrays of len 2 are supported, got %v", t.Elem()))
+		}
+		if !v.IsValid() {
+			// the elements of empty lists and nil pointers have no pairs to select
+			return queryFieldError(path, fmt.Errorf("the selection set of %v is derived from its value, which is missing", t))
 		}
 		sliceOfPairs := v
-		_, _ = io.WriteString(w, "{")
+		b.buf.WriteString("{")
 		for i := 0; i < sliceOfPairs.Len(); i++ {
+			pairPath := fmt.Sprintf("%s[%d]", path, i)
 			pair := sliceOfPairs.Index(i)
+			key, ok := pair.Index(0).Interface().(string)
+			if !ok {
+				return queryFieldError(pairPath, fmt.Errorf("the key must be a string, got %T", pair.Index(0).Interface()))
+			}
 			// it.Value() returns interface{}, so we need to use reflect.ValueOf
 			// to cast it away
-			key, val := pair.Index(0), reflect.ValueOf(pair.Index(1).Interface())
-			_, _ = io.WriteString(w, key.Interface().(string))
-			writeQuery(w, val.Type(), val, false)
+			val := reflect.ValueOf(pair.Index(1).Interface())
+			if !val.IsValid() {
+				return queryFieldError(pairPath, errors.New("the value must not be nil"))
+			}
+			if err := b.writeTag(key, pairPath); err != nil {
+				return err
+			}
+			if err := b.writeQuery(val.Type(), val, pairPath, false); err != nil {
+				return err
+			}
 		}
-		_, _ = io.WriteString(w, "}")
+		b.buf.WriteString("}")
 	case reflect.Map:
-		err := fmt.Errorf("type %v is not supported, use [][2]interface{} instead", t)
-		panic(err.Error())
+		if t.Key().Kind() != reflect.String {
+			return queryFieldError(path, fmt.Errorf("type %v is not supported, map keys must be strings", t))
+		}
+		// empty maps are JSON scalar values, like Hasura's jsonb
+		if !v.IsValid() || v.Len() == 0 {
+			return nil
+		}
+		// handle map[string]interface{} like an ordered map, in the order of the keys
+		b.buf.WriteString("{")
+		for i, key := range sortedMapKeys(v) {
+			if i != 0 {
+				b.buf.WriteString(",")
+			}
+			keyPath := fmt.Sprintf("%s[%q]", path, key.String())
+			if err := b.writeTag(key.String(), keyPath); err != nil {
+				return err
+			}
+			val := v.MapIndex(key)
+			if val.Kind() == reflect.Interface {
+				// nil values are scalar fields
+				if val.IsNil() {
+					continue
+				}
+				val = val.Elem()
+			}
+			if err := b.writeQuery(val.Type(), val, keyPath, false); err != nil {
+				return err
+			}
+		}
+		b.buf.WriteString("}")
+	}
+	return nil
+}
+
+// writeStruct writes the selection set of the fields of struct t to b.
+// If inline is true, the fields are inlined into the parent selection set.
+func (b *queryBuilder) writeStruct(t reflect.Type, v reflect.Value, path string, inline bool) error {
+	if !inline {
+		b.buf.WriteString("{")
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if i != 0 {
+			b.buf.WriteString(",")
+		}
+		f := t.Field(i)
+		fieldPath := joinFieldPath(path, f.Name)
+		value, ok := f.Tag.Lookup("graphql")
+		inlineField := f.Anonymous && !ok
+		directives, hasDirectives := f.Tag.Lookup("directives")
+		if ft, fv, isSpread := fragmentSpreadField(f, FieldSafe(v, i)); isSpread && (inlineField || value == "...") {
+			// spread of a named fragment, e.g. "...UserFields @include(if: $withUser)"
+			name, typeCondition, _ := namedFragment(ft)
+			if err := b.writeFragmentSpread(ft, fv, fieldPath, true, name, typeCondition); err != nil {
+				return err
+			}
+			if hasDirectives {
+				if err := b.writeDirectives(directives, fieldPath); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if inlineField && hasDirectives {
+			return queryFieldError(fieldPath, errors.New("embedded structs can't have directives, use an inline fragment instead"))
+		}
+		if !inlineField {
+			if ok {
+				if err := b.writeTag(value, fieldPath); err != nil {
+					return err
+				}
+			} else {
+				b.buf.WriteString(ident.ParseMixedCaps(f.Name).ToLowerCamelCase())
+			}
+		}
+		if hasDirectives {
+			if err := b.writeDirectives(directives, fieldPath); err != nil {
+				return err
+			}
+		}
+		if err := b.writeQuery(f.Type, FieldSafe(v, i), fieldPath, inlineField); err != nil {
+			return err
+		}
+	}
+	if !inline {
+		b.buf.WriteString("}")
+	}
+	return nil
+}
+
+// fragmentSpreadField returns the struct type and value of field f, if it's a named fragment
+// or a pointer to a named fragment.
+func fragmentSpreadField(f reflect.StructField, v reflect.Value) (reflect.Type, reflect.Value, bool) {
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t, v = t.Elem(), ElemSafe(v)
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, reflect.Value{}, false
+	}
+	_, _, ok := namedFragment(t)
+	return t, v, ok
+}
+
+// writeFragmentSpread writes the spread of the named fragment of struct t to b,
+// and defines the fragment at its first spread. If inline is false,
+// the spread is the only selection of the selection set, e.g. "{...UserFields}".
+func (b *queryBuilder) writeFragmentSpread(t reflect.Type, v reflect.Value, path string, inline bool, name string, typeCondition string) error {
+	if err := b.defineFragment(t, v, path, name, typeCondition); err != nil {
+		return err
+	}
+	if inline {
+		b.buf.WriteString("..." + name)
+	} else {
+		b.buf.WriteString("{..." + name + "}")
+	}
+	return nil
+}
+
+// defineFragment writes the selection set of the named fragment of struct t,
+// unless the fragment is already defined. The fragment is defined before its selection set is written,
+// so that fragments spreading themselves don't recurse.
+func (b *queryBuilder) defineFragment(t reflect.Type, v reflect.Value, path string, name string, typeCondition string) error {
+	for _, fragment := range b.fragments {
+		if fragment.name != name {
+			continue
+		}
+		if fragment.t != t {
+			return queryFieldError(path, fmt.Errorf("fragment %s is defined by both %v and %v", name, fragment.t, t))
+		}
+		return nil
+	}
+	if !isName(name) {
+		return queryFieldError(path, fmt.Errorf("invalid fragment name %q", name))
+	}
+	if !isName(typeCondition) {
+		return queryFieldError(path, fmt.Errorf("invalid type condition %q of fragment %s", typeCondition, name))
+	}
+	fragment := &fragmentDefinition{name: name, typeCondition: typeCondition, t: t}
+	b.fragments = append(b.fragments, fragment)
+
+	buf := b.buf
+	b.buf = &bytes.Buffer{}
+	err := b.writeStruct(t, v, path, false)
+	fragment.selection = b.buf.String()
+	b.buf = buf
+	return err
+}
+
+// namedFragment returns the fragment name and type condition of struct t,
+// if t or *t implements NamedFragment. Structs embedding a named fragment
+// aren't named fragments themselves, although the method is promoted.
+func namedFragment(t reflect.Type) (string, string, bool) {
+	name, typeCondition, ok := fragmentMethod(t)
+	if !ok {
+		return "", "", false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.Anonymous {
+			continue
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if embeddedName, embeddedTypeCondition, ok := fragmentMethod(ft); ok && embeddedName == name && embeddedTypeCondition == typeCondition {
+			return "", "", false
+		}
+	}
+	return name, typeCondition, true
+}
+
+// fragmentMethod calls the GraphQLFragment method of t or *t.
+func fragmentMethod(t reflect.Type) (string, string, bool) {
+	var fragment NamedFragment
+	switch {
+	case t.Implements(namedFragmentType):
+		fragment = reflect.Zero(t).Interface().(NamedFragment)
+	case reflect.PtrTo(t).Implements(namedFragmentType):
+		fragment = reflect.New(t).Interface().(NamedFragment)
+	default:
+		return "", "", false
+	}
+	name, typeCondition := fragment.GraphQLFragment()
+	return name, typeCondition, true
+}
+
+var namedFragmentType = reflect.TypeOf((*NamedFragment)(nil)).Elem()
+
+// isName reports whether s is a valid GraphQL name.
+func isName(s string) bool {
+	if s == "" || (s[0] >= '0' && s[0] <= '9') {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedMapKeys returns the keys of map v with string keys in sorted order.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+// joinFieldPath appends the field name to the Go field path.
+func joinFieldPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// queryFieldError annotates err with the Go field path of the query.
+func queryFieldError(path string, err error) error {
+	if path == "" {
+		return fmt.Errorf("invalid query: %w", err)
 	}
+	return fmt.Errorf("invalid query field %s: %w", path, err)
 }
 
 func IndexSafe(v reflect.Value, i int) reflect.Value {
@@ -3,6 +3,7 @@ package graphql
 import (
 	"fmt"
 	"net/url"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -305,6 +306,44 @@ func TestConstructQuery(t *testing.T) {
 			}{},
 			want: `{viewer{login,createdAt,id,databaseId}}`,
 		},
+		{
+			inV: struct {
+				Hero struct {
+					Name    String
+					Friends []struct {
+						Name String
+					} `directives:"@include(if: $withFriends)"`
+					Height Float  `graphql:"height(unit: METER)" directives:"@skip(if: $brief)"`
+					Avatar String `directives:"@deprecated @experimental(reason: \"beta\")"`
+				}
+			}{},
+			inVariables: map[string]interface{}{
+				"withFriends": Boolean(true),
+				"brief":       Boolean(false),
+			},
+			options: []Option{OperationDirective("@cached(ttl: 120)")},
+			want:    `query ($brief:Boolean!$withFriends:Boolean!) @cached(ttl: 120) {hero{name,friends @include(if: $withFriends){name},height(unit: METER) @skip(if: $brief),avatar @deprecated @experimental(reason: "beta")}}`,
+		},
+		{
+			inV: struct {
+				Viewer struct {
+					UserFields
+					CreatedAt DateTime
+				}
+				Repository struct {
+					*RepositoryFields `directives:"@include(if: $withRepository)"`
+				} `graphql:"repository(owner: \"hasura\", name: \"go-graphql-client\")"`
+				Fork struct {
+					Fields RepositoryFields `graphql:"..."`
+					IsFork Boolean
+				} `graphql:"fork: repository(owner: \"gopher\", name: \"go-graphql-client\")"`
+			}{},
+			inVariables: map[string]interface{}{
+				"first":          Int(10),
+				"withRepository": Boolean(true),
+			},
+			want: `query ($first:Int!$withRepository:Boolean!){viewer{...UserFields,createdAt},repository(owner: "hasura", name: "go-graphql-client"){...RepositoryFields @include(if: $withRepository)},fork: repository(owner: "gopher", name: "go-graphql-client"){...RepositoryFields,isFork}}fragment UserFields on User{login,name}fragment RepositoryFields on Repository{name,owner{...UserFields},issues(first: $first){author{...UserFields}}}`,
+		},
 	}
 	for _, tc := range tests {
 		got, err := constructQuery(tc.inV, tc.inVariables, tc.options...)
@@ -316,6 +355,25 @@ func TestConstructQuery(t *testing.T) {
 	}
 }
 
+type UserFields struct {
+	Login String
+	Name  String
+}
+
+func (UserFields) GraphQLFragment() (string, string) { return "UserFields", "User" }
+
+type RepositoryFields struct {
+	Name  String
+	Owner struct {
+		UserFields
+	}
+	Issues []struct {
+		Author *UserFields
+	} `graphql:"issues(first: $first)"`
+}
+
+func (*RepositoryFields) GraphQLFragment() (string, string) { return "RepositoryFields", "Repository" }
+
 type CreateUser struct {
 	Login string
 }
@@ -361,6 +419,21 @@ func TestConstructMutation(t *testing.T) {
 			},
 			want: "mutation ($login1:String!$login2:String!){createUser(login:$login1){login}deleteUser(login:$login2){login}}",
 		},
+		{
+			inV: map[string]interface{}{
+				"user2:deleteUser(login:$login2)": &DeleteUser{},
+				"user1:createUser(login:$login1)": &CreateUser{},
+				"stats": map[string]interface{}{
+					"count":    nil,
+					"metadata": map[string]interface{}{},
+				},
+			},
+			inVariables: map[string]interface{}{
+				"login1": String("grihabor"),
+				"login2": String("diman"),
+			},
+			want: "mutation ($login1:String!$login2:String!){stats{count,metadata},user1:createUser(login:$login1){login},user2:deleteUser(login:$login2){login}}",
+		},
 	}
 	for _, tc := range tests {
 		got, err := constructMutation(tc.inV, tc.inVariables)
@@ -607,10 +680,102 @@ func TestConstructSubscription(t *testing.T) {
 	}
 }
 
-func TestQueryArguments(t *testing.T) {
+func TestConstructQuery_cache(t *testing.T) {
+	type query struct {
+		Viewer struct {
+			Login string
+		}
+	}
+	type userQuery struct {
+		User struct {
+			Login string
+		} `graphql:"user(id: $id)"`
+	}
 	tests := []struct {
-		in   map[string]interface{}
+		inV         interface{}
+		inVariables map[string]interface{}
+		options     []Option
+		want        string
+	}{
+		{
+			inV:  &query{},
+			want: `{viewer{login}}`,
+		},
+		{
+			inV:     &query{},
+			options: []Option{OperationName("Viewer")},
+			want:    `query Viewer{viewer{login}}`,
+		},
+		{
+			inV:     &query{},
+			options: []Option{OperationName("Viewer"), cachedDirective{ttl: 60}},
+			want:    `query Viewer @cached(ttl: 60) {viewer{login}}`,
+		},
+		{
+			inV:         &userQuery{},
+			inVariables: map[string]interface{}{"id": ID("someID")},
+			want:        `query ($id:ID!){user(id: $id){login}}`,
+		},
+		{
+			inV:         &userQuery{},
+			inVariables: map[string]interface{}{"id": Int(1)},
+			want:        `query ($id:Int!){user(id: $id){login}}`,
+		},
+	}
+	// construct twice, so that the second query is read from the cache
+	for i := 0; i < 2; i++ {
+		for _, tc := range tests {
+			got, err := constructQuery(tc.inV, tc.inVariables, tc.options...)
+			if err != nil {
+				t.Error(err)
+			} else if got != tc.want {
+				t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+			}
+		}
+	}
+
+	// the cache has one selection set per type, without the operation names and variables
+	cached, ok := queryCache.Load(reflect.TypeOf(&query{}))
+	if !ok {
+		t.Fatal("got no cached selection set")
+	}
+	if got, want := cached.(*builtQuery).selection, `{viewer{login}}`; got != want {
+		t.Errorf("got cached selection set: %q, want: %q", got, want)
+	}
+}
+
+func TestConstructQuery_orderedMapNotCached(t *testing.T) {
+	type query struct {
+		Viewer [][2]interface{}
+	}
+	tests := []struct {
+		inV  query
 		want string
+	}{
+		{
+			inV:  query{Viewer: [][2]interface{}{{"login", String("")}}},
+			want: `{viewer{login}}`,
+		},
+		{
+			inV:  query{Viewer: [][2]interface{}{{"name", String("")}}},
+			want: `{viewer{name}}`,
+		},
+	}
+	for _, tc := range tests {
+		got, err := constructQuery(tc.inV, nil)
+		if err != nil {
+			t.Error(err)
+		} else if got != tc.want {
+			t.Errorf("\ngot:  %q\nwant: %q\n", got, tc.want)
+		}
+	}
+}
+
+func TestQueryArguments(t *testing.T) {
+	tests := []struct {
+		in        map[string]interface{}
+		typeNames map[reflect.Type]string
+		want      string
 	}{
 		{
 			in:   map[string]interface{}{"a": Int(123), "b": NewBoolean(true)},
@@ -641,6 +806,10 @@ func TestQueryArguments(t *testing.T) {
 			in:   map[string]interface{}{"id": ID("someID")},
 			want: "$id:ID!",
 		},
+		{
+			in:   map[string]interface{}{"file": Upload{}, "files": []*Upload{}},
+			want: "$file:Upload!$files:[Upload]!",
+		},
 		{
 			in:   map[string]interface{}{"ids": []ID{"someID", "anotherID"}},
 			want: `$ids:[ID!]!`,
@@ -649,15 +818,382 @@ func TestQueryArguments(t *testing.T) {
 			in:   map[string]interface{}{"ids": &[]ID{"someID", "anotherID"}},
 			want: `$ids:[ID!]`,
 		},
+		{
+			in: map[string]interface{}{
+				"id":      UUID{},
+				"ids":     []UUID{},
+				"parent":  (*UUID)(nil),
+				"created": DateTime{},
+				"tags":    Tags{},
+			},
+			want: "$created:timestamptz!$id:uuid!$ids:[uuid!]!$parent:uuid$tags:jsonb!",
+		},
+		{
+			in: map[string]interface{}{
+				"id":     UUID{},
+				"where":  map[string]interface{}{},
+				"wheres": []map[string]interface{}{},
+			},
+			typeNames: map[reflect.Type]string{
+				reflect.TypeOf(UUID{}):                   "ID",
+				reflect.TypeOf(map[string]interface{}{}): "users_bool_exp",
+			},
+			want: "$id:ID!$where:users_bool_exp!$wheres:[users_bool_exp!]!",
+		},
+	}
+	for i, tc := range tests {
+		got, err := queryArguments(tc.in, nil, tc.typeNames)
+		if err != nil {
+			t.Errorf("test case %d: %v", i, err)
+		} else if got != tc.want {
+			t.Errorf("test case %d:\n got: %q\nwant: %q", i, got, tc.want)
+		}
+	}
+}
+
+// GraphQLTyper is a variable type whose values declare their GraphQL types.
+type GraphQLTyper interface {
+	GraphQLType() string
+}
+
+func TestQueryArguments_interfaceTypes(t *testing.T) {
+	typerType := reflect.TypeOf((*GraphQLTyper)(nil)).Elem()
+	tests := []struct {
+		in        map[string]interface{}
+		types     map[string]reflect.Type
+		typeNames map[reflect.Type]string
+		want      string
+		wantErr   string
+	}{
+		{
+			in:    map[string]interface{}{"id": UUID{}, "created": &DateTime{}},
+			types: map[string]reflect.Type{"id": typerType, "created": typerType},
+			want:  "$created:timestamptz$id:uuid!",
+		},
+		{
+			in:        map[string]interface{}{"id": UUID{}},
+			types:     map[string]reflect.Type{"id": typerType},
+			typeNames: map[reflect.Type]string{typerType: "ID"},
+			want:      "$id:ID!",
+		},
+		{
+			in:      map[string]interface{}{"id": nil},
+			types:   map[string]reflect.Type{"id": typerType},
+			wantErr: "invalid variable id: the GraphQL type of interface graphql.GraphQLTyper is declared by its value, which is nil",
+		},
+		{
+			in:      map[string]interface{}{"ids": []GraphQLTyper{UUID{}}},
+			wantErr: "invalid variable ids: the GraphQL type of interface graphql.GraphQLTyper is declared by its values, use a concrete type or WithGraphQLType",
+		},
 	}
 	for i, tc := range tests {
-		got := queryArguments(tc.in)
-		if got != tc.want {
+		got, err := queryArguments(tc.in, tc.types, tc.typeNames)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("test case %d: got error: %v, want: %s", i, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test case %d: %v", i, err)
+		} else if got != tc.want {
 			t.Errorf("test case %d:\n got: %q\nwant: %q", i, got, tc.want)
 		}
 	}
 }
 
+type otherUserFields struct {
+	Login String
+}
+
+func (otherUserFields) GraphQLFragment() (string, string) { return "UserFields", "User" }
+
+type invalidFragment struct {
+	Login String
+}
+
+func (invalidFragment) GraphQLFragment() (string, string) { return "InvalidFragment", "User!" }
+
+func TestConstructQuery_errors(t *testing.T) {
+	type user struct {
+		Login String
+	}
+	tests := []struct {
+		inV         interface{}
+		inVariables map[string]interface{}
+		options     []Option
+		want        string
+	}{
+		{
+			inV:  nil,
+			want: "invalid query: nil",
+		},
+		{
+			inV: struct {
+				Viewer struct {
+					Friends map[int]user
+				}
+			}{},
+			want: "invalid query field Viewer.Friends: type map[int]graphql.user is not supported, map keys must be strings",
+		},
+		{
+			inV: struct {
+				Viewer map[string]interface{}
+			}{Viewer: map[string]interface{}{"user(id: 1": user{}}},
+			want: `invalid query field Viewer["user(id: 1"]: malformed graphql tag "user(id: 1": unclosed '('`,
+		},
+		{
+			inV: struct {
+				Users [][3]interface{}
+			}{},
+			want: "invalid query field Users: only arrays of len 2 are supported, got [3]interface {}",
+		},
+		{
+			inV: struct {
+				Viewer *[][2]interface{}
+			}{},
+			want: "invalid query field Viewer: the selection set of [][2]interface {} is derived from its value, which is missing",
+		},
+		{
+			inV: struct {
+				Nodes []struct {
+					Viewer [][2]interface{}
+				}
+			}{},
+			want: "invalid query field Nodes.Viewer: the selection set of [][2]interface {} is derived from its value, which is missing",
+		},
+		{
+			inV: struct {
+				Users [][2]interface{}
+			}{Users: [][2]interface{}{{"user(id: 1)", user{}}, {1, user{}}}},
+			want: "invalid query field Users[1]: the key must be a string, got int",
+		},
+		{
+			inV: struct {
+				Users [][2]interface{}
+			}{Users: [][2]interface{}{{"user(id: 1)", nil}}},
+			want: "invalid query field Users[0]: the value must not be nil",
+		},
+		{
+			inV: struct {
+				Repository struct {
+					Issue struct {
+						Title String
+					} `graphql:"issue(number: $issueNumber"`
+				}
+			}{},
+			want: `invalid query field Repository.Issue: malformed graphql tag "issue(number: $issueNumber": unclosed '('`,
+		},
+		{
+			inV: struct {
+				User user `graphql:"user(login: \"gopher)"`
+			}{},
+			want: `invalid query field User: malformed graphql tag "user(login: \"gopher)": unterminated string`,
+		},
+		{
+			inV: struct {
+				User user `graphql:"user(ids: [1, 2)]"`
+			}{},
+			want: `invalid query field User: malformed graphql tag "user(ids: [1, 2)]": unexpected ')'`,
+		},
+		{
+			inV: struct {
+				User user `graphql:""`
+			}{},
+			want: `invalid query field User: malformed graphql tag "": empty tag`,
+		},
+		{
+			inV: struct {
+				User user `graphql:"user(id: $id)"`
+			}{},
+			inVariables: map[string]interface{}{"id": nil},
+			want:        "invalid variable id: the type of a nil value is unknown, use a typed nil pointer instead",
+		},
+		{
+			inV: struct {
+				User user `graphql:"user(filter: $filter)"`
+			}{},
+			inVariables: map[string]interface{}{"filter": map[string]interface{}{}},
+			want:        "invalid variable filter: type map[string]interface {} has no name to use as the GraphQL type",
+		},
+		{
+			inV: struct {
+				User user `graphql:"user(callback: $callback)"`
+			}{},
+			inVariables: map[string]interface{}{"callback": func() {}},
+			want:        "invalid variable callback: type func() is not supported",
+		},
+		{
+			inV: struct {
+				Hero struct {
+					Friends []user `directives:"@include(if: $withFriends)"`
+				}
+			}{},
+			want: "invalid query field Hero.Friends: variable $withFriends is not defined",
+		},
+		{
+			inV: struct {
+				User user `directives:"include(if: true)"`
+			}{},
+			want: `invalid query field User: malformed directives tag "include(if: true)": expected a directive`,
+		},
+		{
+			inV: struct {
+				user `directives:"@include(if: true)"`
+			}{},
+			want: "invalid query field user: embedded structs can't have directives, use an inline fragment instead",
+		},
+		{
+			inV: struct {
+				Repository struct {
+					Issue struct {
+						Title String
+					} `graphql:"issue(number: $issueNumber)"`
+				} `graphql:"repository(owner: $owner, name: $name)"`
+			}{},
+			inVariables: map[string]interface{}{"owner": String("shurcooL-test"), "name": String("test-repo")},
+			want:        "invalid query field Repository.Issue: variable $issueNumber is not defined",
+		},
+		{
+			inV: struct {
+				Users [][2]interface{}
+			}{Users: [][2]interface{}{{"user(id: $id)", user{}}}},
+			want: "invalid query field Users[0]: variable $id is not defined",
+		},
+		{
+			inV: struct {
+				User user `graphql:"user(id: $id)"`
+			}{},
+			inVariables: map[string]interface{}{"id": ID("someID"), "first": Int(10)},
+			want:        "invalid variable first: not used by the query",
+		},
+		{
+			inV: struct {
+				User user `graphql:"user(id: $)"`
+			}{},
+			want: `invalid query field User: malformed graphql tag "user(id: $)": expected a variable name after $`,
+		},
+		{
+			inV: struct {
+				User user
+			}{},
+			options: []Option{cachedDirective{ttl: 60}, OperationDirective("@rest(ttl: $ttl)")},
+			want:    "invalid operation directive @rest(ttl: $ttl): variable $ttl is not defined",
+		},
+		{
+			inV: struct {
+				User user `graphql:"user(filter: $filter)"`
+			}{},
+			inVariables: map[string]interface{}{"filter": struct{ Login String }{}},
+			want:        "invalid variable filter: type struct { Login graphql.String } has no name to use as the GraphQL type",
+		},
+		{
+			inV: struct {
+				Repository RepositoryFields
+			}{},
+			want: "invalid query field Repository.Issues: variable $first is not defined",
+		},
+		{
+			inV: struct {
+				Viewer UserFields
+				User   otherUserFields
+			}{},
+			want: "invalid query field User: fragment UserFields is defined by both graphql.UserFields and graphql.otherUserFields",
+		},
+		{
+			inV: struct {
+				Viewer invalidFragment
+			}{},
+			want: `invalid query field Viewer: invalid type condition "User!" of fragment InvalidFragment`,
+		},
+	}
+	for i, tc := range tests {
+		_, err := constructQuery(tc.inV, tc.inVariables, tc.options...)
+		if err == nil {
+			t.Errorf("test case %d: got no error, want: %q", i, tc.want)
+		} else if got := err.Error(); got != tc.want {
+			t.Errorf("test case %d:\n got: %q\nwant: %q", i, got, tc.want)
+		}
+	}
+}
+
+func TestVariablesMap(t *testing.T) {
+	type Pagination struct {
+		First *Int
+		After *String `graphql:"cursor"`
+	}
+	type variables struct {
+		ID     ID
+		Filter AddReactionInput `graphql:"input"`
+		Skip   String           `graphql:"-"`
+		Pagination
+		unexported String
+	}
+	first := Int(10)
+	tests := []struct {
+		in      interface{}
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			in:   nil,
+			want: nil,
+		},
+		{
+			in:   map[string]interface{}{"id": ID("someID")},
+			want: map[string]interface{}{"id": ID("someID")},
+		},
+		{
+			in:   map[string]String{"login": "gopher"},
+			want: map[string]interface{}{"login": String("gopher")},
+		},
+		{
+			in: &variables{
+				ID:         ID("someID"),
+				Filter:     AddReactionInput{Content: ReactionContentHeart},
+				Pagination: Pagination{First: &first},
+			},
+			want: map[string]interface{}{
+				"id":     ID("someID"),
+				"input":  AddReactionInput{Content: ReactionContentHeart},
+				"first":  &first,
+				"cursor": (*String)(nil),
+			},
+		},
+		{
+			in:      []String{"gopher"},
+			wantErr: "invalid variables type []graphql.String, must be a map with string keys or a struct",
+		},
+		{
+			in: struct {
+				ID  ID
+				Key ID `graphql:"id"`
+			}{},
+			wantErr: "invalid variables field Key: duplicate variable id",
+		},
+		{
+			in: struct {
+				ID ID `graphql:"$id"`
+			}{},
+			wantErr: `invalid variables field ID: invalid variable name "$id"`,
+		},
+	}
+	for i, tc := range tests {
+		got, _, err := variablesMap(tc.in)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("test case %d: got error: %v, want: %s", i, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test case %d: %v", i, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("test case %d:\n got: %#v\nwant: %#v", i, got, tc.want)
+		}
+	}
+}
+
 // Custom GraphQL types for testing.
 type (
 	// DateTime is an ISO-8601 encoded UTC date.
@@ -669,6 +1205,18 @@ type (
 
 func (u *URI) UnmarshalJSON(data []byte) error { panic("mock implementation") }
 
+func (DateTime) GraphQLType() string { return "timestamptz" }
+
+// UUID is a universally unique identifier, the uuid scalar type of Hasura.
+type UUID [16]byte
+
+func (UUID) GraphQLType() string { return "uuid" }
+
+// Tags is a JSON list of tags, the jsonb scalar type of Hasura.
+type Tags []string
+
+func (*Tags) GraphQLType() string { return "jsonb" }
+
 // IssueState represents the possible states of an issue.
 type IssueState string
 
@@ -701,3 +1249,53 @@ type AddReactionInput struct {
 	// A unique identifier for the client performing the mutation. (Optional.)
 	ClientMutationID *String `json:"clientMutationId,omitempty"`
 }
+
+type Character interface {
+	CharacterName() string
+}
+
+type Human struct {
+	Name    String
+	Height  Float
+	Friends []Character
+}
+
+func (h Human) CharacterName() string { return string(h.Name) }
+
+type DroidFields struct {
+	Name            String
+	PrimaryFunction String
+}
+
+func (d *DroidFields) CharacterName() string { return string(d.Name) }
+
+func (*DroidFields) GraphQLFragment() (string, string) { return "DroidFields", "Droid" }
+
+func TestConstructQuery_typeRegistry(t *testing.T) {
+	type heroQuery struct {
+		Hero   Character `graphql:"hero(episode: EMPIRE)"`
+		Search []*Character
+		ID     ID
+	}
+	droids := (*typeRegistry)(nil).with("Droid", reflect.TypeOf(&DroidFields{}))
+	got, err := constructOperation("query", heroQuery{}, nil, operationTypes{registry: droids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{hero(episode: EMPIRE){__typename,...DroidFields},search{__typename,...DroidFields},id}fragment DroidFields on Droid{name,primaryFunction}`; got != want {
+		t.Errorf("\ngot:  %q\nwant: %q\n", got, want)
+	}
+	// selection sets built with a registry are cached in the registry
+	if _, ok := droids.queries.Load(reflect.TypeOf(heroQuery{})); !ok {
+		t.Error("got no selection set cached in the registry")
+	}
+	if _, ok := queryCache.Load(reflect.TypeOf(heroQuery{})); ok {
+		t.Error("got selection set of the registry cached in queryCache")
+	}
+
+	// the query of the same type is constructed again for another registry
+	_, err = constructOperation("query", heroQuery{}, nil, operationTypes{registry: droids.with("Human", reflect.TypeOf(Human{}))})
+	if want := "invalid query field Hero.(graphql.Human).Friends: the selection of interface graphql.Character is recursive, use a struct type for the nested selection"; err == nil || err.Error() != want {
+		t.Errorf("got error: %v, want: %s", err, want)
+	}
+}
@@ -0,0 +1,137 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy configures retries of failed HTTP operations with exponential backoff and jitter.
+// Zero fields are set to the defaults of DefaultRetryPolicy.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the wait time before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum wait time between attempts.
+	MaxBackoff time.Duration
+	// Multiplier increases the wait time after each attempt.
+	Multiplier float64
+	// Jitter is the fraction of the wait time, between 0 and 1, that is randomized,
+	// so that clients failing at the same time don't retry at the same time.
+	Jitter float64
+	// RetryMutations allows retrying mutations. By default only queries are retried,
+	// because mutations may not be idempotent.
+	RetryMutations bool
+	// RetryErrorCodes are the extension codes of GraphQL errors that are worth retrying.
+	RetryErrorCodes []string
+	// ShouldRetry classifies the error of an attempt, a transport error, HTTPError
+	// or the GraphQL Errors of the response. If nil, DefaultShouldRetry is used.
+	ShouldRetry func(policy RetryPolicy, err error) bool
+}
+
+// DefaultRetryPolicy returns the default retry policy. It retries queries up to 3 times
+// with backoff from 100 milliseconds up to 5 seconds.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		ShouldRetry:    DefaultShouldRetry,
+	}
+}
+
+// DefaultShouldRetry retries transport errors, such as connection resets, HTTP status codes
+// 408, 429, 502, 503 and 504, and GraphQL errors with one of the RetryErrorCodes of the policy.
+// Errors of canceled contexts are never retried.
+func DefaultShouldRetry(policy RetryPolicy, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var gqlErrs Errors
+	if errors.As(err, &gqlErrs) {
+		return len(policy.RetryErrorCodes) > 0 && gqlErrs.HasCode(policy.RetryErrorCodes...)
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+// WithRetry enables retries of failed operations with the policy.
+// The number of attempts of an operation can be read with the BindAttempts option.
+func (c *Client) WithRetry(policy RetryPolicy) *Client {
+	defaults := DefaultRetryPolicy()
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = defaults.MaxAttempts
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = defaults.InitialBackoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = defaults.MaxBackoff
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = defaults.Multiplier
+	}
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		policy.Jitter = defaults.Jitter
+	}
+	if policy.ShouldRetry == nil {
+		policy.ShouldRetry = defaults.ShouldRetry
+	}
+	c.retryPolicy = &policy
+	return c
+}
+
+// retry reports whether the operation should be attempted again after the failure err.
+func (p *RetryPolicy) retry(op operationType, attempt int, err error) bool {
+	if p == nil || err == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if op == mutationOperation && !p.RetryMutations {
+		return false
+	}
+	return p.ShouldRetry(*p, err)
+}
+
+// backoff returns the wait time after the attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	backoff -= backoff * p.Jitter * rand.Float64()
+	return time.Duration(backoff)
+}
+
+// wait sleeps before the next attempt. It returns false if the context
+// is done or its deadline would pass before the next attempt.
+func (p *RetryPolicy) wait(ctx context.Context, attempt int) bool {
+	backoff := p.backoff(attempt)
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+		return false
+	}
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,100 @@
+package graphql_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+func TestClient_Query_retry(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Content-Type", "application/json")
+			mustWrite(w, `{"errors": [{"message": "database is busy", "extensions": {"code": "unexpected"}}]}`)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+		}
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithRetry(graphql.RetryPolicy{
+			MaxAttempts:     5,
+			InitialBackoff:  time.Millisecond,
+			RetryErrorCodes: []string{graphql.ErrorCodeUnexpected},
+		})
+
+	var q struct {
+		User struct {
+			Name string
+		}
+	}
+	var attempts int
+	err := client.Query(context.Background(), &q, nil, graphql.BindAttempts(&attempts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := q.User.Name, "Gopher"; got != want {
+		t.Errorf("got q.User.Name: %q, want: %q", got, want)
+	}
+	if got, want := attempts, 3; got != want {
+		t.Errorf("got attempts: %d, want: %d", got, want)
+	}
+}
+
+func TestClient_Mutate_noRetry(t *testing.T) {
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithRetry(graphql.RetryPolicy{InitialBackoff: time.Millisecond})
+
+	var m struct {
+		DeleteUser struct {
+			ID graphql.ID
+		} `graphql:"deleteUser(id: 1)"`
+	}
+	var attempts int
+	err := client.Mutate(context.Background(), &m, nil, graphql.BindAttempts(&attempts))
+	if err == nil {
+		t.Fatal("got error: nil, want: non-nil")
+	}
+	if got, want := attempts, 1; got != want {
+		t.Errorf("got attempts: %d, want: %d", got, want)
+	}
+
+	// give up before the context deadline passes
+	client.WithRetry(graphql.RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Hour, RetryMutations: true})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err = client.Mutate(ctx, &m, nil, graphql.BindAttempts(&attempts))
+	var httpErr *graphql.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got error: %v, want: graphql.HTTPError with status code 503", err)
+	}
+	if got, want := attempts, 1; got != want {
+		t.Errorf("got attempts: %d, want: %d", got, want)
+	}
+
+	requests = 0
+	client.WithRetry(graphql.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, RetryMutations: true})
+	err = client.Mutate(context.Background(), &m, nil)
+	if err == nil {
+		t.Fatal("got error: nil, want: non-nil")
+	}
+	if got, want := requests, 2; got != want {
+		t.Errorf("got requests: %d, want: %d", got, want)
+	}
+}
@@ -35,6 +35,14 @@ type (
 	String string
 )
 
+// GraphQLType is implemented by variable types whose GraphQL type name differs from their Go type name,
+// e.g. a UUID type for the uuid scalar of Hasura. The name is used in the variable declarations
+// of the operation. Pointers are nullable and slices are lists, like for other types.
+type GraphQLType interface {
+	// GraphQLType returns the name of the GraphQL type, e.g. "uuid".
+	GraphQLType() string
+}
+
 // NewBoolean is a helper to make a new *Boolean.
 func NewBoolean(v Boolean) *Boolean { return &v }
 
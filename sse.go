@@ -0,0 +1,447 @@
+package graphql
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context/ctxhttp"
+	"nhooyr.io/websocket"
+)
+
+// GraphQL over Server-Sent Events follows the graphql-sse protocol specification
+// https://github.com/enisdenjo/graphql-sse/blob/master/PROTOCOL.md
+
+// SSEMode is the connection mode of the graphql-sse protocol.
+type SSEMode int
+
+const (
+	// SSEDistinctConnections opens an event stream for each subscription.
+	SSEDistinctConnections SSEMode = iota
+	// SSESingleConnection reserves one event stream for all subscriptions.
+	// Subscriptions are started and stopped with separate HTTP requests.
+	SSESingleConnection
+)
+
+// sseTokenHeader is the header of the stream token in single connection mode.
+const sseTokenHeader = "X-GraphQL-Event-Stream-Token"
+
+// WithSSE switches the subscription transport from WebSocket to GraphQL over Server-Sent Events,
+// for networks that block WebSocket upgrades. The URL of the client must be an HTTP URL.
+// If httpClient is nil, http.DefaultClient is used.
+//
+// The Subscribe API, handlers, reconnection and OnError events are the same for both transports.
+// The "headers" object of the connection params, if any, is sent as the HTTP headers of all requests.
+func (sc *SubscriptionClient) WithSSE(httpClient *http.Client, mode SSEMode) *SubscriptionClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	sc.createConn = func(sc *SubscriptionClient) (WebsocketConn, error) {
+		return newSSEConn(sc, httpClient, mode), nil
+	}
+	return sc
+}
+
+// sseConn implements the WebsocketConn interface with graphql-sse requests.
+// It translates the messages of the subscription client into HTTP requests,
+// and the events of the streams into messages.
+type sseConn struct {
+	url        string
+	httpClient *http.Client
+	mode       SSEMode
+	timeout    time.Duration
+	ctx        context.Context
+	cancel     context.CancelFunc
+	messages   chan OperationMessage
+	errs       chan error
+
+	mu        sync.Mutex
+	headers   http.Header
+	readLimit int64
+	// token is the stream token in single connection mode
+	token string
+	// operations are the cancel functions of the running operations
+	operations map[string]context.CancelFunc
+}
+
+func newSSEConn(sc *SubscriptionClient, httpClient *http.Client, mode SSEMode) *sseConn {
+	ctx, cancel := context.WithCancel(sc.GetContext())
+	return &sseConn{
+		url:        sc.GetURL(),
+		httpClient: httpClient,
+		mode:       mode,
+		timeout:    sc.GetTimeout(),
+		ctx:        ctx,
+		cancel:     cancel,
+		messages:   make(chan OperationMessage),
+		errs:       make(chan error, 1),
+		headers:    make(http.Header),
+		operations: make(map[string]context.CancelFunc),
+	}
+}
+
+// ReadJSON reads the next message translated from the event streams.
+func (c *sseConn) ReadJSON(v interface{}) error {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case msg := <-c.messages:
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	case err := <-c.errs:
+		return err
+	case <-c.ctx.Done():
+		return websocket.CloseError{Code: websocket.StatusNormalClosure, Reason: "event stream closed"}
+	case <-timer.C:
+		return context.DeadlineExceeded
+	}
+}
+
+// WriteJSON translates the message of the subscription client into graphql-sse requests.
+func (c *sseConn) WriteJSON(v interface{}) error {
+	msg, ok := v.(OperationMessage)
+	if !ok {
+		return fmt.Errorf("unsupported message type %T", v)
+	}
+	switch msg.Type {
+	case GQL_CONNECTION_INIT:
+		return c.connect(msg.Payload)
+	case GQL_START:
+		c.start(msg.ID, msg.Payload)
+	case GQL_STOP:
+		c.stop(msg.ID)
+	}
+	return nil
+}
+
+// Close closes all event streams.
+func (c *sseConn) Close() error {
+	c.cancel()
+	return nil
+}
+
+// Subprotocol returns the protocol of the messages that the connection translates.
+func (c *sseConn) Subprotocol() string {
+	return string(SubscriptionsTransportWS)
+}
+
+// SetReadLimit sets the maximum size in bytes of an event.
+func (c *sseConn) SetReadLimit(limit int64) {
+	c.mu.Lock()
+	c.readLimit = limit
+	c.mu.Unlock()
+}
+
+// connect applies the headers of the connection params and, in single connection mode,
+// reserves the stream and opens it.
+func (c *sseConn) connect(payload json.RawMessage) error {
+	if len(payload) > 0 {
+		var params struct {
+			Headers map[string]interface{} `json:"headers"`
+		}
+		if err := json.Unmarshal(payload, &params); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		for key, value := range params.Headers {
+			c.headers.Set(key, fmt.Sprint(value))
+		}
+		c.mu.Unlock()
+	}
+
+	if c.mode == SSESingleConnection {
+		token, err := c.reserve()
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.token = token
+		c.mu.Unlock()
+
+		req, err := c.newRequest(c.ctx, http.MethodGet, c.url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.openStream(req)
+		if err != nil {
+			return err
+		}
+		go func() {
+			err := c.readStream(resp.Body, "")
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
+			c.fail(err)
+		}()
+	}
+
+	go c.send(OperationMessage{Type: GQL_CONNECTION_ACK})
+	return nil
+}
+
+// reserve reserves an event stream and returns its token.
+func (c *sseConn) reserve() (string, error) {
+	req, err := c.newRequest(c.ctx, http.MethodPut, c.url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := ctxhttp.Do(c.ctx, c.httpClient, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// start starts the operation with the payload of the start message.
+func (c *sseConn) start(id string, payload json.RawMessage) {
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.mu.Lock()
+	c.operations[id] = cancel
+	c.mu.Unlock()
+
+	go func() {
+		err := c.execute(ctx, id, payload)
+		if err != nil && ctx.Err() == nil {
+			c.reject(id, err)
+		}
+	}()
+}
+
+// execute sends the operation. In distinct connections mode, it reads the events
+// of the operation until the stream is complete.
+func (c *sseConn) execute(ctx context.Context, id string, payload json.RawMessage) error {
+	if c.mode == SSESingleConnection {
+		var in map[string]interface{}
+		if err := json.Unmarshal(payload, &in); err != nil {
+			return err
+		}
+		in["extensions"] = map[string]interface{}{"operationId": id}
+		body, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		req, err := c.newRequest(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := ctxhttp.Do(ctx, c.httpClient, req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+		}
+		return nil
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.openStream(req)
+	if err != nil {
+		return err
+	}
+	err = c.readStream(resp.Body, id)
+	if err != nil && ctx.Err() == nil {
+		// the connection was lost, reconnect
+		c.fail(fmt.Errorf("event stream of subscription %s: %w", id, err))
+	}
+	return nil
+}
+
+// stop stops the operation, if it's running.
+func (c *sseConn) stop(id string) {
+	c.mu.Lock()
+	cancel, ok := c.operations[id]
+	delete(c.operations, id)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	cancel()
+
+	if c.mode == SSESingleConnection {
+		go func() {
+			u, err := url.Parse(c.url)
+			if err != nil {
+				return
+			}
+			query := u.Query()
+			query.Set("operationId", id)
+			u.RawQuery = query.Encode()
+			req, err := c.newRequest(c.ctx, http.MethodDelete, u.String(), nil)
+			if err != nil {
+				return
+			}
+			resp, err := ctxhttp.Do(c.ctx, c.httpClient, req)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+	}
+}
+
+// openStream sends the request of an event stream and checks the response status.
+func (c *sseConn) openStream(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := ctxhttp.Do(req.Context(), c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	}
+	return resp, nil
+}
+
+// readStream reads the events of the stream and sends them as messages.
+// id is the operation of the stream in distinct connections mode. In single connection mode,
+// the events have the operation id. It returns nil after a complete event in distinct connections mode.
+func (c *sseConn) readStream(body io.ReadCloser, id string) error {
+	defer body.Close()
+
+	c.mu.Lock()
+	readLimit := c.readLimit
+	c.mu.Unlock()
+
+	scanner := bufio.NewScanner(body)
+	if readLimit > 0 {
+		scanner.Buffer(make([]byte, 0, 4096), int(readLimit))
+	}
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			// dispatch the event
+			if event == "" && len(data) == 0 {
+				continue
+			}
+			complete, err := c.dispatch(event, strings.Join(data, "\n"), id)
+			if err != nil {
+				return err
+			}
+			if complete && id != "" {
+				return nil
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// comment, e.g. a keep-alive
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// dispatch sends the message of the event. It reports whether the event completes the operation.
+func (c *sseConn) dispatch(event string, data string, id string) (bool, error) {
+	var payload json.RawMessage
+	if c.mode == SSESingleConnection && data != "" {
+		var msg struct {
+			ID      string          `json:"id"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return false, err
+		}
+		id, payload = msg.ID, msg.Payload
+	} else if data != "" {
+		payload = json.RawMessage(data)
+	}
+
+	switch event {
+	case "next":
+		c.send(OperationMessage{ID: id, Type: GQL_DATA, Payload: payload})
+	case "complete":
+		c.mu.Lock()
+		delete(c.operations, id)
+		c.mu.Unlock()
+		c.send(OperationMessage{ID: id, Type: GQL_COMPLETE})
+		return true, nil
+	}
+	return false, nil
+}
+
+// reject sends the error of an operation that couldn't be started as an error message.
+func (c *sseConn) reject(id string, err error) {
+	c.mu.Lock()
+	delete(c.operations, id)
+	c.mu.Unlock()
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"errors": []map[string]interface{}{{"message": err.Error()}},
+	})
+	c.send(OperationMessage{ID: id, Type: GQL_ERROR, Payload: payload})
+}
+
+// send sends the message to the reader, unless the connection is closed.
+func (c *sseConn) send(msg OperationMessage) {
+	select {
+	case c.messages <- msg:
+	case <-c.ctx.Done():
+	}
+}
+
+// fail reports an error of the connection to the reader, unless the connection is closed.
+func (c *sseConn) fail(err error) {
+	if c.ctx.Err() != nil {
+		return
+	}
+	select {
+	case c.errs <- err:
+	default:
+	}
+}
+
+// newRequest creates a request with the headers of the connection params and the stream token.
+func (c *sseConn) newRequest(ctx context.Context, method string, u string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, values := range c.headers {
+		req.Header[key] = values
+	}
+	if c.token != "" {
+		req.Header.Set(sseTokenHeader, c.token)
+	}
+	return req, nil
+}
@@ -0,0 +1,121 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// sseServer implements both connection modes of the graphql-sse protocol.
+// Every subscription receives one message and completes.
+func sseServer(t *testing.T) *httptest.Server {
+	operations := make(chan string, 10)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("Authorization"), "Bearer token"; got != want {
+			t.Errorf("got Authorization header: %q, want: %q", got, want)
+		}
+		token := req.Header.Get("X-GraphQL-Event-Stream-Token")
+		switch {
+		case req.Method == http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+			mustWrite(w, "stream-token")
+		case req.Method == http.MethodGet && token == "stream-token":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case id := <-operations:
+					mustWrite(w, fmt.Sprintf("event: next\ndata: {\"id\":%q,\"payload\":{\"data\":{\"user\":{\"name\":\"Gopher\"}}}}\n\n", id))
+					mustWrite(w, fmt.Sprintf("event: complete\ndata: {\"id\":%q}\n\n", id))
+					w.(http.Flusher).Flush()
+				case <-req.Context().Done():
+					return
+				}
+			}
+		case req.Method == http.MethodPost && token == "stream-token":
+			var in struct {
+				Query      string
+				Extensions struct {
+					OperationID string `json:"operationId"`
+				}
+			}
+			if err := json.NewDecoder(req.Body).Decode(&in); err != nil {
+				t.Error(err)
+			}
+			operations <- in.Extensions.OperationID
+			w.WriteHeader(http.StatusAccepted)
+		case req.Method == http.MethodPost:
+			if got, want := req.Header.Get("Accept"), "text/event-stream"; got != want {
+				t.Errorf("got Accept header: %q, want: %q", got, want)
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			mustWrite(w, ": keep-alive\n\n")
+			mustWrite(w, "event: next\ndata: {\"data\":{\"user\":{\"name\":\"Gopher\"}}}\n\n")
+			mustWrite(w, "event: complete\ndata:\n\n")
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestSubscriptionClient_sse(t *testing.T) {
+	for name, mode := range map[string]graphql.SSEMode{
+		"distinct": graphql.SSEDistinctConnections,
+		"single":   graphql.SSESingleConnection,
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := sseServer(t)
+			defer server.Close()
+
+			received := make(chan string, 1)
+			// stop running at the first read timeout after the data is received
+			client := graphql.NewSubscriptionClient(server.URL).
+				WithConnectionParams(map[string]interface{}{
+					"headers": map[string]string{"Authorization": "Bearer token"},
+				}).
+				WithSSE(server.Client(), mode).
+				WithTimeout(100 * time.Millisecond).
+				OnError(func(sc *graphql.SubscriptionClient, err error) error {
+					if len(received) > 0 {
+						return err
+					}
+					return nil
+				})
+
+			var sub struct {
+				User struct {
+					Name string
+				}
+			}
+			_, err := client.Subscribe(&sub, nil, func(data *json.RawMessage, err error) error {
+				if err != nil {
+					t.Error(err)
+					return nil
+				}
+				received <- string(*data)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = client.Run()
+			if err != context.DeadlineExceeded {
+				t.Errorf("got error: %v, want: %v", err, context.DeadlineExceeded)
+			}
+			if err := client.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := <-received, `{"user":{"name":"Gopher"}}`; got != want {
+				t.Errorf("got data: %s, want: %s", got, want)
+			}
+		})
+	}
+}
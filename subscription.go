@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -17,6 +18,18 @@ import (
 
 // Subscription transport follow Apollo's subscriptions-transport-ws protocol specification
 // https://github.com/apollographql/subscriptions-transport-ws/blob/master/PROTOCOL.md
+// and the graphql-transport-ws protocol specification of graphql-ws
+// https://github.com/enisdenjo/graphql-ws/blob/master/PROTOCOL.md
+
+// SubscriptionProtocolType is the WebSocket subprotocol of a subscription protocol.
+type SubscriptionProtocolType string
+
+const (
+	// SubscriptionsTransportWS is the legacy subscriptions-transport-ws protocol
+	SubscriptionsTransportWS SubscriptionProtocolType = "graphql-ws"
+	// GraphQLTransportWS is the graphql-transport-ws protocol of the graphql-ws library
+	GraphQLTransportWS SubscriptionProtocolType = "graphql-transport-ws"
+)
 
 // OperationMessageType
 type OperationMessageType string
@@ -43,6 +56,14 @@ const (
 	GQL_CONNECTION_ACK OperationMessageType = "connection_ack"
 	// Client sends this message to terminate the connection.
 	GQL_CONNECTION_TERMINATE OperationMessageType = "connection_terminate"
+	// graphql-transport-ws: Client sends this message to execute GraphQL operation
+	GQL_SUBSCRIBE OperationMessageType = "subscribe"
+	// graphql-transport-ws: The server sends this message to transfer the GraphQL execution result of the operation. The error message payload is an array of errors, and complete is sent in both directions.
+	GQL_NEXT OperationMessageType = "next"
+	// graphql-transport-ws: Either side may send this message to check the connection. The receiver must respond with GQL_PONG.
+	GQL_PING OperationMessageType = "ping"
+	// graphql-transport-ws: The response to the GQL_PING message.
+	GQL_PONG OperationMessageType = "pong"
 	// Unknown operation type, for logging only
 	GQL_UNKNOWN OperationMessageType = "unknown"
 	// Internal status, for logging only
@@ -80,6 +101,24 @@ type subscription struct {
 	variables map[string]interface{}
 	handler   func(data *json.RawMessage, err error)
 	started   Boolean
+	// extensions is the value that the extensions of data messages are decoded into
+	extensions   interface{}
+	extensionsMu sync.Mutex
+}
+
+// handleWithExtensions decodes the message extensions into the bound value and calls the handler.
+// The lock keeps the value consistent with the data until the handler returns
+func (s *subscription) handleWithExtensions(data *json.RawMessage, extensions *json.RawMessage) {
+	s.extensionsMu.Lock()
+	defer s.extensionsMu.Unlock()
+
+	if extensions != nil {
+		if err := json.Unmarshal(*extensions, s.extensions); err != nil {
+			s.handler(nil, fmt.Errorf("failed to decode response extensions: %w", err))
+			return
+		}
+	}
+	s.handler(data, nil)
 }
 
 // SubscriptionClient is a GraphQL subscription client.
@@ -93,6 +132,7 @@ type SubscriptionClient struct {
 	subscribersMu    sync.Mutex
 	timeout          time.Duration
 	isRunning        int32
+	isAcknowledged   int32 // the server acknowledged the connection of the graphql-transport-ws protocol
 	readLimit        int64 // max size of response message. Default 10 MB
 	log              func(args ...interface{})
 	createConn       func(sc *SubscriptionClient) (WebsocketConn, error)
@@ -102,6 +142,13 @@ type SubscriptionClient struct {
 	onError          func(sc *SubscriptionClient, err error) error
 	errorChan        chan error
 	disabledLogTypes []OperationMessageType
+	tracer           Tracer
+	// protocol is the configured protocol. If empty, the protocol is negotiated
+	protocol SubscriptionProtocolType
+	// connProtocol is the protocol of the current connection
+	connProtocol SubscriptionProtocolType
+	// typeNames are the GraphQL type names of Go types in variable declarations
+	typeNames map[reflect.Type]string
 }
 
 func NewSubscriptionClient(url string) *SubscriptionClient {
@@ -169,12 +216,35 @@ func (sc *SubscriptionClient) WithoutLogTypes(types ...OperationMessageType) *Su
 	return sc
 }
 
+// WithProtocol sets the subscription protocol explicitly.
+// By default, both protocols are offered as WebSocket subprotocols, and the protocol selected by the server is used.
+// If the server doesn't select a subprotocol, the legacy subscriptions-transport-ws protocol is used.
+func (sc *SubscriptionClient) WithProtocol(protocol SubscriptionProtocolType) *SubscriptionClient {
+	sc.protocol = protocol
+	return sc
+}
+
+// GetProtocol returns the protocol of the current connection
+func (sc *SubscriptionClient) GetProtocol() SubscriptionProtocolType {
+	return sc.connProtocol
+}
+
 // WithReadLimit set max size of response message
 func (sc *SubscriptionClient) WithReadLimit(limit int64) *SubscriptionClient {
 	sc.readLimit = limit
 	return sc
 }
 
+// WithGraphQLType declares the GraphQL type name of the Go type of v, used in the variable declarations
+// of the subscriptions, like Client.WithGraphQLType
+func (sc *SubscriptionClient) WithGraphQLType(v interface{}, name string) *SubscriptionClient {
+	if sc.typeNames == nil {
+		sc.typeNames = make(map[reflect.Type]string)
+	}
+	sc.typeNames[reflect.TypeOf(v)] = name
+	return sc
+}
+
 // OnConnected event is triggered when there is any connection error. This is bottom exception handler level
 // If this function is empty, or returns nil, the error is ignored
 // If returns error, the websocket connection will be terminated
@@ -203,13 +273,21 @@ func (sc *SubscriptionClient) setIsRunning(value Boolean) {
 	}
 }
 
-func (sc *SubscriptionClient) init() error {
+func (sc *SubscriptionClient) init() (err error) {
 
 	now := time.Now()
+	atomic.StoreInt32(&sc.isAcknowledged, 0)
 	ctx, cancel := context.WithCancel(context.Background())
 	sc.context = ctx
 	sc.cancel = cancel
 
+	spanCtx, span := startSpan(sc.tracer, ctx, SpanSubscriptionConnect, map[string]interface{}{
+		AttributeHTTPURL: sc.url,
+	})
+	defer func() {
+		span.End(err)
+	}()
+
 	for {
 		var err error
 		var conn WebsocketConn
@@ -222,9 +300,10 @@ func (sc *SubscriptionClient) init() error {
 		}
 
 		if err == nil {
+			sc.connProtocol = sc.negotiatedProtocol()
 			sc.conn.SetReadLimit(sc.readLimit)
 			// send connection init event to the server
-			err = sc.sendConnectionInit()
+			err = sc.sendConnectionInit(spanCtx)
 		}
 
 		if err == nil {
@@ -242,6 +321,21 @@ func (sc *SubscriptionClient) init() error {
 	}
 }
 
+// negotiatedProtocol returns the subprotocol selected by the server, if the connection exposes it,
+// or the configured protocol.
+func (sc *SubscriptionClient) negotiatedProtocol() SubscriptionProtocolType {
+	if conn, ok := sc.conn.(interface{ Subprotocol() string }); ok {
+		switch protocol := SubscriptionProtocolType(conn.Subprotocol()); protocol {
+		case SubscriptionsTransportWS, GraphQLTransportWS:
+			return protocol
+		}
+	}
+	if sc.protocol != "" {
+		return sc.protocol
+	}
+	return SubscriptionsTransportWS
+}
+
 func (sc *SubscriptionClient) printLog(message interface{}, opType OperationMessageType) {
 	if sc.log == nil {
 		return
@@ -255,11 +349,11 @@ func (sc *SubscriptionClient) printLog(message interface{}, opType OperationMess
 	sc.log(message)
 }
 
-func (sc *SubscriptionClient) sendConnectionInit() (err error) {
+func (sc *SubscriptionClient) sendConnectionInit(ctx context.Context) (err error) {
 	var bParams []byte = nil
-	if sc.connectionParams != nil {
+	if sc.connectionParams != nil || sc.tracer != nil {
 
-		bParams, err = json.Marshal(sc.connectionParams)
+		bParams, err = json.Marshal(injectConnectionParams(sc.tracer, ctx, sc.connectionParams))
 		if err != nil {
 			return
 		}
@@ -278,60 +372,105 @@ func (sc *SubscriptionClient) sendConnectionInit() (err error) {
 // Subscribe sends start message to server and open a channel to receive data.
 // The handler callback function will receive raw message data or error. If the call return error, onError event will be triggered
 // The function returns subscription ID and error. You can use subscription ID to unsubscribe the subscription
-func (sc *SubscriptionClient) Subscribe(v interface{}, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
+// variables is a map or a struct of variables, like in Client.Query
+func (sc *SubscriptionClient) Subscribe(v interface{}, variables interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
 	return sc.do(v, variables, handler, options...)
 }
 
 // NamedSubscribe sends start message to server and open a channel to receive data, with operation name
 //
 // Deprecated: this is the shortcut of Subscribe method, with NewOperationName option
-func (sc *SubscriptionClient) NamedSubscribe(name string, v interface{}, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
+func (sc *SubscriptionClient) NamedSubscribe(name string, v interface{}, variables interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
 	return sc.do(v, variables, handler, append(options, OperationName(name))...)
 }
 
 // SubscribeRaw sends start message to server and open a channel to receive data, with raw query
-func (sc *SubscriptionClient) SubscribeRaw(query string, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error) (string, error) {
-	return sc.doRaw(query, variables, handler)
+func (sc *SubscriptionClient) SubscribeRaw(query string, variables interface{}, handler func(message *json.RawMessage, err error) error) (string, error) {
+	vars, _, err := variablesMap(variables)
+	if err != nil {
+		return "", err
+	}
+	return sc.doRaw(query, vars, handler, nil)
 }
 
-func (sc *SubscriptionClient) do(v interface{}, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
-	query, err := constructSubscription(v, variables, options...)
+func (sc *SubscriptionClient) do(v interface{}, variables interface{}, handler func(message *json.RawMessage, err error) error, options ...Option) (string, error) {
+	vars, variableTypes, err := variablesMap(variables)
+	if err != nil {
+		return "", err
+	}
+	types := operationTypes{variables: variableTypes, names: sc.typeNames}
+	query, err := constructOperation("subscription", v, vars, types, options...)
+	if err != nil {
+		return "", err
+	}
+	optionsOutput, err := constructOptions(options)
 	if err != nil {
 		return "", err
 	}
 
-	return sc.doRaw(query, variables, handler)
+	return sc.doRaw(query, vars, handler, optionsOutput.extensions)
 }
 
-func (sc *SubscriptionClient) doRaw(query string, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error) (string, error) {
+func (sc *SubscriptionClient) doRaw(query string, variables map[string]interface{}, handler func(message *json.RawMessage, err error) error, extensions interface{}) (string, error) {
 	id := uuid.New().String()
 
 	sub := subscription{
-		query:     query,
-		variables: variables,
-		handler:   sc.wrapHandler(handler),
+		query:      query,
+		variables:  variables,
+		handler:    sc.wrapHandler(id, handler),
+		extensions: extensions,
 	}
 
+	sc.subscribersMu.Lock()
+	defer sc.subscribersMu.Unlock()
+
 	// if the websocket client is running, start subscription immediately
-	if atomic.LoadInt32(&sc.isRunning) > 0 {
+	if sc.canStartSubscriptions() {
 		if err := sc.startSubscription(id, &sub); err != nil {
 			return "", err
 		}
 	}
-
-	sc.subscribersMu.Lock()
 	sc.subscriptions[id] = &sub
-	sc.subscribersMu.Unlock()
 
 	return id, nil
 }
 
+// canStartSubscriptions reports whether subscriptions can be sent to the server.
+// The graphql-transport-ws protocol doesn't allow subscribing before the connection is acknowledged.
+func (sc *SubscriptionClient) canStartSubscriptions() bool {
+	if atomic.LoadInt32(&sc.isRunning) == 0 {
+		return false
+	}
+	return sc.connProtocol != GraphQLTransportWS || atomic.LoadInt32(&sc.isAcknowledged) > 0
+}
+
+// startSubscriptions starts the pending subscriptions. The subscription that fails to start is removed.
+func (sc *SubscriptionClient) startSubscriptions() error {
+	sc.subscribersMu.Lock()
+	defer sc.subscribersMu.Unlock()
+	for k, v := range sc.subscriptions {
+		if err := sc.startSubscription(k, v); err != nil {
+			sc.internalUnsubscribe(k)
+			return err
+		}
+	}
+	return nil
+}
+
 // Subscribe sends start message to server and open a channel to receive data
-func (sc *SubscriptionClient) startSubscription(id string, sub *subscription) error {
+func (sc *SubscriptionClient) startSubscription(id string, sub *subscription) (err error) {
 	if sub == nil || sub.started {
 		return nil
 	}
 
+	_, span := startSpan(sc.tracer, context.Background(), SpanSubscriptionStart, map[string]interface{}{
+		AttributeOperationType:  "subscription",
+		AttributeSubscriptionID: id,
+	})
+	defer func() {
+		span.End(err)
+	}()
+
 	in := struct {
 		Query     string                 `json:"query"`
 		Variables map[string]interface{} `json:"variables,omitempty"`
@@ -345,14 +484,18 @@ func (sc *SubscriptionClient) startSubscription(id string, sub *subscription) er
 		return err
 	}
 
-	// send stop message to the server
+	// send start message to the server
+	msgType := GQL_START
+	if sc.connProtocol == GraphQLTransportWS {
+		msgType = GQL_SUBSCRIBE
+	}
 	msg := OperationMessage{
 		ID:      id,
-		Type:    GQL_START,
+		Type:    msgType,
 		Payload: payload,
 	}
 
-	sc.printLog(msg, GQL_START)
+	sc.printLog(msg, msgType)
 	if err := sc.conn.WriteJSON(msg); err != nil {
 		return err
 	}
@@ -361,9 +504,18 @@ func (sc *SubscriptionClient) startSubscription(id string, sub *subscription) er
 	return nil
 }
 
-func (sc *SubscriptionClient) wrapHandler(fn handlerFunc) func(data *json.RawMessage, err error) {
+func (sc *SubscriptionClient) wrapHandler(id string, fn handlerFunc) func(data *json.RawMessage, err error) {
 	return func(data *json.RawMessage, err error) {
-		if errValue := fn(data, err); errValue != nil {
+		_, span := startSpan(sc.tracer, context.Background(), SpanSubscriptionData, map[string]interface{}{
+			AttributeSubscriptionID: id,
+		})
+		errValue := fn(data, err)
+		if err != nil {
+			span.End(err)
+		} else {
+			span.End(errValue)
+		}
+		if errValue != nil {
 			sc.errorChan <- errValue
 		}
 	}
@@ -375,16 +527,12 @@ func (sc *SubscriptionClient) Run() error {
 		return fmt.Errorf("retry timeout. exiting...")
 	}
 
-	// lazily start subscriptions
-	sc.subscribersMu.Lock()
-	for k, v := range sc.subscriptions {
-		if err := sc.startSubscription(k, v); err != nil {
-			sc.internalUnsubscribe(k)
-			sc.subscribersMu.Unlock()
+	// lazily start subscriptions. The graphql-transport-ws protocol starts them when the connection is acknowledged
+	if sc.connProtocol != GraphQLTransportWS {
+		if err := sc.startSubscriptions(); err != nil {
 			return err
 		}
 	}
-	sc.subscribersMu.Unlock()
 
 	sc.setIsRunning(true)
 
@@ -427,8 +575,12 @@ func (sc *SubscriptionClient) Run() error {
 			switch message.Type {
 			case GQL_ERROR:
 				sc.printLog(message, GQL_ERROR)
+				if sc.connProtocol == GraphQLTransportWS {
+					sc.handleOperationErrors(message)
+					continue
+				}
 				fallthrough
-			case GQL_DATA:
+			case GQL_DATA, GQL_NEXT:
 				sc.printLog(message, GQL_DATA)
 				id, err := uuid.Parse(message.ID)
 				if err != nil {
@@ -443,9 +595,9 @@ func (sc *SubscriptionClient) Run() error {
 					continue
 				}
 				var out struct {
-					Data   *json.RawMessage
-					Errors errors
-					//Extensions interface{} // Unused.
+					Data       *json.RawMessage
+					Errors     Errors
+					Extensions *json.RawMessage
 				}
 
 				err = json.Unmarshal(message.Payload, &out)
@@ -458,16 +610,46 @@ func (sc *SubscriptionClient) Run() error {
 					continue
 				}
 
+				if sub.extensions != nil {
+					go sub.handleWithExtensions(out.Data, out.Extensions)
+					continue
+				}
+
 				go sub.handler(out.Data, nil)
 			case GQL_CONNECTION_ERROR:
 				sc.printLog(message, GQL_CONNECTION_ERROR)
 			case GQL_COMPLETE:
 				sc.printLog(message, GQL_COMPLETE)
+				if sc.connProtocol == GraphQLTransportWS {
+					// the server completed the operation, so complete isn't sent back
+					sc.subscribersMu.Lock()
+					delete(sc.subscriptions, message.ID)
+					sc.subscribersMu.Unlock()
+					continue
+				}
 				sc.Unsubscribe(message.ID)
+			case GQL_PING:
+				sc.printLog(message, GQL_PING)
+				pong := OperationMessage{
+					Type:    GQL_PONG,
+					Payload: message.Payload,
+				}
+				sc.printLog(pong, GQL_PONG)
+				if err := sc.conn.WriteJSON(pong); err != nil {
+					sc.printLog(err.Error(), GQL_INTERNAL)
+				}
+			case GQL_PONG:
+				sc.printLog(message, GQL_PONG)
 			case GQL_CONNECTION_KEEP_ALIVE:
 				sc.printLog(message, GQL_CONNECTION_KEEP_ALIVE)
 			case GQL_CONNECTION_ACK:
 				sc.printLog(message, GQL_CONNECTION_ACK)
+				if sc.connProtocol == GraphQLTransportWS {
+					atomic.StoreInt32(&sc.isAcknowledged, 1)
+					if err := sc.startSubscriptions(); err != nil {
+						return err
+					}
+				}
 				if sc.onConnected != nil {
 					sc.onConnected()
 				}
@@ -484,6 +666,26 @@ func (sc *SubscriptionClient) Run() error {
 
 	return sc.Reset()
 }
+
+// handleOperationErrors handles the error message of the graphql-transport-ws protocol.
+// Its payload is an array of errors, and the operation is terminated.
+func (sc *SubscriptionClient) handleOperationErrors(message OperationMessage) {
+	sc.subscribersMu.Lock()
+	sub, ok := sc.subscriptions[message.ID]
+	delete(sc.subscriptions, message.ID)
+	sc.subscribersMu.Unlock()
+	if !ok {
+		return
+	}
+
+	var errs Errors
+	if err := json.Unmarshal(message.Payload, &errs); err != nil {
+		go sub.handler(nil, err)
+		return
+	}
+	go sub.handler(nil, errs)
+}
+
 func (sc *SubscriptionClient) internalUnsubscribe(id string) error {
 	_, ok := sc.subscriptions[id]
 	if !ok {
@@ -504,15 +706,26 @@ func (sc *SubscriptionClient) Unsubscribe(id string) error {
 	return sc.internalUnsubscribe(id)
 }
 
-func (sc *SubscriptionClient) stopSubscription(id string) error {
+func (sc *SubscriptionClient) stopSubscription(id string) (err error) {
 	if sc.conn != nil {
+		_, span := startSpan(sc.tracer, context.Background(), SpanSubscriptionStop, map[string]interface{}{
+			AttributeSubscriptionID: id,
+		})
+		defer func() {
+			span.End(err)
+		}()
+
 		// send stop message to the server
+		msgType := GQL_STOP
+		if sc.connProtocol == GraphQLTransportWS {
+			msgType = GQL_COMPLETE
+		}
 		msg := OperationMessage{
 			ID:   id,
-			Type: GQL_STOP,
+			Type: msgType,
 		}
 
-		sc.printLog(msg, GQL_STOP)
+		sc.printLog(msg, msgType)
 		if err := sc.conn.WriteJSON(msg); err != nil {
 			return err
 		}
@@ -523,7 +736,8 @@ func (sc *SubscriptionClient) stopSubscription(id string) error {
 }
 
 func (sc *SubscriptionClient) terminate() error {
-	if sc.conn != nil {
+	// the graphql-transport-ws protocol terminates the connection by closing the socket
+	if sc.conn != nil && sc.connProtocol != GraphQLTransportWS {
 		// send terminate message to the server
 		msg := OperationMessage{
 			Type: GQL_CONNECTION_TERMINATE,
@@ -612,8 +826,12 @@ func (wh *WebsocketHandler) Close() error {
 
 func newWebsocketConn(sc *SubscriptionClient) (WebsocketConn, error) {
 
+	subprotocols := []string{string(SubscriptionsTransportWS), string(GraphQLTransportWS)}
+	if sc.protocol != "" {
+		subprotocols = []string{string(sc.protocol)}
+	}
 	options := &websocket.DialOptions{
-		Subprotocols: []string{"graphql-ws"},
+		Subprotocols: subprotocols,
 	}
 	c, _, err := websocket.Dial(sc.GetContext(), sc.GetURL(), options)
 	if err != nil {
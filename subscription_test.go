@@ -0,0 +1,124 @@
+package graphql_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// transportWSConn is a messageConn that negotiated the graphql-transport-ws subprotocol.
+// It records the messages written before the connection_ack message is read.
+type transportWSConn struct {
+	messageConn
+	beforeAck        func()
+	writtenBeforeAck []graphql.OperationMessageType
+}
+
+func (c *transportWSConn) ReadJSON(v interface{}) error {
+	c.mu.Lock()
+	ack := len(c.messages) > 0 && c.messages[0].Type == graphql.GQL_CONNECTION_ACK
+	c.mu.Unlock()
+	if ack && c.beforeAck != nil {
+		c.beforeAck()
+	}
+	c.mu.Lock()
+	if ack {
+		for _, msg := range c.written {
+			c.writtenBeforeAck = append(c.writtenBeforeAck, msg.Type)
+		}
+	}
+	c.mu.Unlock()
+	return c.messageConn.ReadJSON(v)
+}
+
+func (c *transportWSConn) Subprotocol() string {
+	return string(graphql.GraphQLTransportWS)
+}
+
+func TestSubscriptionClient_graphqlTransportWS(t *testing.T) {
+	conn := &transportWSConn{}
+	client := graphql.NewSubscriptionClient("ws://localhost/graphql").
+		WithWebSocket(func(sc *graphql.SubscriptionClient) (graphql.WebsocketConn, error) {
+			return conn, nil
+		})
+
+	var sub struct {
+		User struct {
+			Name string
+		}
+	}
+	received := make(chan string, 1)
+	id, err := client.Subscribe(&sub, nil, func(data *json.RawMessage, err error) error {
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+		received <- string(*data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// subscribe while the client is running, but the connection isn't acknowledged yet
+	received2 := make(chan string, 1)
+	var id2 string
+	conn.beforeAck = func() {
+		var err error
+		id2, err = client.Subscribe(&sub, nil, func(data *json.RawMessage, err error) error {
+			if err != nil {
+				t.Error(err)
+				return nil
+			}
+			received2 <- string(*data)
+			return nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		conn.mu.Lock()
+		conn.messages = append(conn.messages,
+			graphql.OperationMessage{ID: id2, Type: graphql.GQL_NEXT, Payload: json.RawMessage(`{"data": {"user": {"name": "Gopher 2"}}}`)},
+			graphql.OperationMessage{ID: id2, Type: graphql.GQL_COMPLETE},
+		)
+		conn.mu.Unlock()
+	}
+	conn.messages = []graphql.OperationMessage{
+		{Type: graphql.GQL_CONNECTION_ACK},
+		{Type: graphql.GQL_PING},
+		{ID: id, Type: graphql.GQL_NEXT, Payload: json.RawMessage(`{"data": {"user": {"name": "Gopher"}}}`)},
+		{ID: id, Type: graphql.GQL_COMPLETE},
+	}
+
+	if err := client.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := <-received, `{"user": {"name": "Gopher"}}`; got != want {
+		t.Errorf("got data: %s, want: %s", got, want)
+	}
+	if got, want := <-received2, `{"user": {"name": "Gopher 2"}}`; got != want {
+		t.Errorf("got data: %s, want: %s", got, want)
+	}
+	if got, want := client.GetProtocol(), graphql.GraphQLTransportWS; got != want {
+		t.Errorf("got protocol: %s, want: %s", got, want)
+	}
+
+	// subscriptions are only sent after the connection_ack message is read
+	if got, want := conn.writtenBeforeAck, []graphql.OperationMessageType{graphql.GQL_CONNECTION_INIT}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages written before connection_ack: %v, want: %v", got, want)
+	}
+	var types []graphql.OperationMessageType
+	for _, msg := range conn.written {
+		types = append(types, msg.Type)
+	}
+	want := []graphql.OperationMessageType{graphql.GQL_CONNECTION_INIT, graphql.GQL_SUBSCRIBE, graphql.GQL_SUBSCRIBE, graphql.GQL_PONG}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("got written messages: %v, want: %v", types, want)
+	}
+	for _, msg := range conn.written[1:3] {
+		if got, want := string(msg.Payload), `{"query":"subscription{user{name}}"}`; got != want {
+			t.Errorf("got subscribe payload: %s, want: %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package graphql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// graphqlTag is a parsed graphql struct tag, or a key of an ordered map or map,
+// e.g. `user(login: $login) @include(if: $withUser)`.
+type graphqlTag struct {
+	// variables are the names of the variables referenced by the arguments and directives,
+	// in the order of their first reference.
+	variables []string
+}
+
+// parseGraphQLTag parses the field, inline fragment or directive of a graphql tag.
+// It reports empty tags, unbalanced brackets, unterminated strings and invalid variable references.
+func parseGraphQLTag(tag string) (*graphqlTag, error) {
+	p := tagParser{tag: tag}
+	if err := p.parse(); err != nil {
+		return nil, fmt.Errorf("malformed graphql tag %q: %w", tag, err)
+	}
+	return &p.result, nil
+}
+
+type tagParser struct {
+	tag    string
+	i      int
+	result graphqlTag
+}
+
+func (p *tagParser) parse() error {
+	p.skipIgnored()
+	if p.i == len(p.tag) {
+		return errors.New("empty tag")
+	}
+	if c := p.tag[p.i]; !isNameChar(c) && c != '.' && c != '@' {
+		return fmt.Errorf("unexpected %q, expected a field name", c)
+	}
+
+	var brackets []byte
+	for p.skipIgnored(); p.i < len(p.tag); p.skipIgnored() {
+		c := p.tag[p.i]
+		switch c {
+		case '"':
+			if err := p.skipString(); err != nil {
+				return err
+			}
+			continue
+		case '$':
+			p.i++
+			name := p.readName()
+			if name == "" {
+				return errors.New("expected a variable name after $")
+			}
+			p.addVariable(name)
+			continue
+		case '(', '[', '{':
+			brackets = append(brackets, c)
+		case ')', ']', '}':
+			var err error
+			if brackets, err = closeBracket(brackets, c); err != nil {
+				return err
+			}
+		}
+		p.i++
+	}
+	if len(brackets) > 0 {
+		return fmt.Errorf("unclosed %q", brackets[len(brackets)-1])
+	}
+	return nil
+}
+
+// skipDefinition skips the rest of a definition of a GraphQL document,
+// up to the end of its selection set, e.g. the variable definitions, directives and selection set of an operation.
+func (p *tagParser) skipDefinition() error {
+	var brackets []byte
+	for p.skipIgnored(); p.i < len(p.tag); p.skipIgnored() {
+		c := p.tag[p.i]
+		switch c {
+		case '"':
+			if err := p.skipString(); err != nil {
+				return err
+			}
+			continue
+		case '(', '[', '{':
+			brackets = append(brackets, c)
+		case ')', ']', '}':
+			var err error
+			if brackets, err = closeBracket(brackets, c); err != nil {
+				return err
+			}
+			if c == '}' && len(brackets) == 0 {
+				p.i++
+				return nil
+			}
+		}
+		p.i++
+	}
+	if len(brackets) > 0 {
+		return fmt.Errorf("unclosed %q", brackets[len(brackets)-1])
+	}
+	return errors.New("expected a selection set")
+}
+
+// closeBracket pops the opening bracket of the closing bracket c from brackets.
+func closeBracket(brackets []byte, c byte) ([]byte, error) {
+	open := map[byte]byte{')': '(', ']': '[', '}': '{'}[c]
+	if len(brackets) == 0 || brackets[len(brackets)-1] != open {
+		return brackets, fmt.Errorf("unexpected %q", c)
+	}
+	return brackets[:len(brackets)-1], nil
+}
+
+// skipIgnored skips white space, commas and comments.
+func (p *tagParser) skipIgnored() {
+	for p.i < len(p.tag) {
+		switch p.tag[p.i] {
+		case ' ', '\t', '\n', '\r', ',':
+			p.i++
+		case '#':
+			for p.i < len(p.tag) && p.tag[p.i] != '\n' {
+				p.i++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// skipString skips a string or block string value.
+func (p *tagParser) skipString() error {
+	if strings.HasPrefix(p.tag[p.i:], `"""`) {
+		end := strings.Index(p.tag[p.i+3:], `"""`)
+		for end != -1 && p.tag[p.i+3+end-1] == '\\' {
+			// escaped triple quote
+			next := strings.Index(p.tag[p.i+3+end+3:], `"""`)
+			if next == -1 {
+				end = -1
+				break
+			}
+			end += 3 + next
+		}
+		if end == -1 {
+			return errors.New("unterminated block string")
+		}
+		p.i += 3 + end + 3
+		return nil
+	}
+	for p.i++; p.i < len(p.tag); p.i++ {
+		switch p.tag[p.i] {
+		case '\\':
+			p.i++
+		case '"':
+			p.i++
+			return nil
+		case '\n':
+			return errors.New("unterminated string")
+		}
+	}
+	return errors.New("unterminated string")
+}
+
+func (p *tagParser) readName() string {
+	start := p.i
+	for p.i < len(p.tag) && isNameChar(p.tag[p.i]) {
+		p.i++
+	}
+	return p.tag[start:p.i]
+}
+
+func (p *tagParser) addVariable(name string) {
+	for _, v := range p.result.variables {
+		if v == name {
+			return
+		}
+	}
+	p.result.variables = append(p.result.variables, name)
+}
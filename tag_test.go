@@ -0,0 +1,99 @@
+package graphql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGraphQLTag(t *testing.T) {
+	tests := []struct {
+		in            string
+		wantVariables []string
+		wantErr       string
+	}{
+		{in: "viewer"},
+		{in: "... on User"},
+		{in: "...UserFields"},
+		{in: "@cached(ttl: 60)"},
+		{
+			in:            "a1: user(login: $login, first: $first) @include(if: $withUser)",
+			wantVariables: []string{"login", "first", "withUser"},
+		},
+		{
+			in:            `search(query: "$notVariable \" (", filter: {ids: [$id, $id]})`,
+			wantVariables: []string{"id"},
+		},
+		{
+			in:            `search(query: """block "string" $notVariable \""" """, first: $first)`,
+			wantVariables: []string{"first"},
+		},
+		{in: "", wantErr: `malformed graphql tag "": empty tag`},
+		{in: "  ", wantErr: `malformed graphql tag "  ": empty tag`},
+		{in: "(id: 1)", wantErr: `malformed graphql tag "(id: 1)": unexpected '(', expected a field name`},
+		{in: "user(id: 1", wantErr: `malformed graphql tag "user(id: 1": unclosed '('`},
+		{in: "user(id: 1))", wantErr: `malformed graphql tag "user(id: 1))": unexpected ')'`},
+		{in: "user(ids: [1)]", wantErr: `malformed graphql tag "user(ids: [1)]": unexpected ')'`},
+		{in: `user(login: "gopher)`, wantErr: `malformed graphql tag "user(login: \"gopher)": unterminated string`},
+		{in: `user(bio: """gopher)`, wantErr: `malformed graphql tag "user(bio: \"\"\"gopher)": unterminated block string`},
+		{in: "user(id: $)", wantErr: `malformed graphql tag "user(id: $)": expected a variable name after $`},
+	}
+	for _, tc := range tests {
+		got, err := parseGraphQLTag(tc.in)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("%q: got error: %v, want: %s", tc.in, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.variables, tc.wantVariables) {
+			t.Errorf("%q: got variables: %q, want: %q", tc.in, got.variables, tc.wantVariables)
+		}
+	}
+}
+
+func TestDocumentOperationType(t *testing.T) {
+	tests := []struct {
+		document      string
+		operationName string
+		want          operationType
+		wantErr       string
+	}{
+		{document: "{ user { name } }", want: queryOperation},
+		{document: "# comment\nmutation { deleteUser }", want: mutationOperation},
+		{
+			document: `fragment F on User { f(x: "}", y: """ { """) } query Q($f: Filter = {a: "("}) { user(filter: $f) { ...F } }`,
+			want:     queryOperation,
+		},
+		{document: "query A { a } mutation B { b }", operationName: "B", want: mutationOperation},
+		{document: "query A { a } mutation B { b }", operationName: "A", want: queryOperation},
+		{
+			document: "query A { a } mutation B { b }",
+			wantErr:  "invalid GraphQL document: the document has 2 operations, use the OperationName option to select one",
+		},
+		{document: "query A { a }", operationName: "B", wantErr: "invalid GraphQL document: operation B not found"},
+		{document: "fragment F on User { name }", wantErr: "invalid GraphQL document: no operation found"},
+		{document: `query { user(name: "x) { name } }`, wantErr: "invalid GraphQL document: unterminated string"},
+		{document: "query { user { name }", wantErr: `invalid GraphQL document: unclosed '{'`},
+		{document: "query A", wantErr: "invalid GraphQL document: expected a selection set"},
+		{document: "type User { name: String }", wantErr: `invalid GraphQL document: unexpected "type"`},
+		{document: "subscription { user }", wantErr: "subscription operations aren't supported by Client, use SubscriptionClient instead"},
+	}
+	for _, tc := range tests {
+		got, err := documentOperationType(tc.document, tc.operationName)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("%s: got error: %v, want: %s", tc.document, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.document, err)
+		} else if got != tc.want {
+			t.Errorf("%s: got %v, want: %v", tc.document, got, tc.want)
+		}
+	}
+}
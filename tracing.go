@@ -0,0 +1,106 @@
+package graphql
+
+import (
+	"context"
+)
+
+// Names of the spans that the clients start.
+const (
+	SpanConstruct           = "graphql.construct"
+	SpanHTTP                = "graphql.http"
+	SpanDecode              = "graphql.decode"
+	SpanSubscriptionConnect = "graphql.subscription.connect"
+	SpanSubscriptionStart   = "graphql.subscription.start"
+	SpanSubscriptionData    = "graphql.subscription.data"
+	SpanSubscriptionStop    = "graphql.subscription.stop"
+)
+
+// Attributes of the spans.
+const (
+	AttributeOperationType  = "graphql.operation.type"
+	AttributeOperationName  = "graphql.operation.name"
+	AttributeSubscriptionID = "graphql.subscription.id"
+	AttributeHTTPMethod     = "http.method"
+	AttributeHTTPURL        = "http.url"
+	AttributeHTTPStatusCode = "http.status_code"
+)
+
+// Tracer starts spans of the client operations. It doesn't depend on a tracing SDK,
+// so OpenTelemetry or any other tracer can be plugged in with a small adapter.
+type Tracer interface {
+	// Start starts a span as the child of the span in ctx, if any,
+	// and returns a context that carries the new span.
+	Start(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, Span)
+	// Inject writes the trace context of the span in ctx with the set function,
+	// e.g. the W3C traceparent header, so that server-side traces are linked to the client span.
+	Inject(ctx context.Context, set func(key, value string))
+}
+
+// Span is a unit of work started by a Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span.
+	SetAttribute(key string, value interface{})
+	// End ends the span. err is the error of the work, if any.
+	End(err error)
+}
+
+// WithTracer sets the tracer of the client operations.
+// Query construction, HTTP round trips and response decoding are traced,
+// and the trace context is injected into the HTTP request headers.
+func (c *Client) WithTracer(tracer Tracer) *Client {
+	c.tracer = tracer
+	return c
+}
+
+// WithTracer sets the tracer of the subscriptions. Connections, starts, data messages
+// and stops of subscriptions are traced, and the trace context of the connection
+// is injected into the payload of the connection_init message.
+func (sc *SubscriptionClient) WithTracer(tracer Tracer) *SubscriptionClient {
+	sc.tracer = tracer
+	return sc
+}
+
+// startSpan starts a span with the tracer, or a no-op span if the tracer is nil.
+func startSpan(tracer Tracer, ctx context.Context, name string, attributes map[string]interface{}) (context.Context, Span) {
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.Start(ctx, name, attributes)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) End(err error)                              {}
+
+// injectConnectionParams returns a copy of the connection params with the trace context of ctx.
+// If the params have a headers object, like the Hasura connection_init payload,
+// the trace context is added to the headers.
+func injectConnectionParams(tracer Tracer, ctx context.Context, params map[string]interface{}) map[string]interface{} {
+	if tracer == nil {
+		return params
+	}
+	out := make(map[string]interface{}, len(params)+1)
+	for key, value := range params {
+		out[key] = value
+	}
+	target := out
+	switch headers := params["headers"].(type) {
+	case map[string]interface{}:
+		target = make(map[string]interface{}, len(headers)+1)
+		for key, value := range headers {
+			target[key] = value
+		}
+		out["headers"] = target
+	case map[string]string:
+		target = make(map[string]interface{}, len(headers)+1)
+		for key, value := range headers {
+			target[key] = value
+		}
+		out["headers"] = target
+	}
+	tracer.Inject(ctx, func(key, value string) {
+		target[key] = value
+	})
+	return out
+}
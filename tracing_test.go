@@ -0,0 +1,219 @@
+package graphql_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+	"nhooyr.io/websocket"
+)
+
+type spanKey struct{}
+
+// recordingTracer records the ended spans as "parent > name" strings.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	name   string
+	parent string
+	attrs  map[string]interface{}
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attributes map[string]interface{}) (context.Context, graphql.Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	span := &recordingSpan{tracer: t, name: name, parent: parent, attrs: attributes}
+	return context.WithValue(ctx, spanKey{}, name), span
+}
+
+func (t *recordingTracer) Inject(ctx context.Context, set func(key, value string)) {
+	if name, ok := ctx.Value(spanKey{}).(string); ok {
+		set("traceparent", name)
+	}
+}
+
+func (t *recordingTracer) Spans() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.spans...)
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.attrs[key] = value
+}
+
+func (s *recordingSpan) End(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	span := s.parent + " > " + s.name
+	if err != nil {
+		span += ": " + err.Error()
+	}
+	s.tracer.spans = append(s.tracer.spans, span)
+}
+
+func TestClient_Query_tracing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("traceparent"), graphql.SpanHTTP; got != want {
+			t.Errorf("got traceparent header: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"user": {"name": "Gopher"}}}`)
+	})
+	tracer := &recordingTracer{}
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithTracer(tracer)
+
+	var q struct {
+		User struct {
+			Name string
+		}
+	}
+	ctx := context.WithValue(context.Background(), spanKey{}, "root")
+	err := client.Query(ctx, &q, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"root > " + graphql.SpanConstruct,
+		"root > " + graphql.SpanHTTP,
+		"root > " + graphql.SpanDecode,
+	}
+	if got := tracer.Spans(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got spans: %q, want: %q", got, want)
+	}
+}
+
+func TestClient_Query_batchingTracing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if got, want := req.Header.Get("traceparent"), graphql.SpanHTTP; got != want {
+			t.Errorf("got traceparent header: %q, want: %q", got, want)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `[{"data": {"user": {"name": "Gopher"}}}, {"data": {"user": {"name": "Gopher"}}}]`)
+	})
+	tracer := &recordingTracer{}
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}}).
+		WithTracer(tracer).
+		WithBatching(time.Minute, 2)
+
+	var wg sync.WaitGroup
+	for _, root := range []string{"root1", "root2"} {
+		wg.Add(1)
+		go func(root string) {
+			defer wg.Done()
+			var q struct {
+				User struct {
+					Name string
+				}
+			}
+			ctx := context.WithValue(context.Background(), spanKey{}, root)
+			if err := client.Query(ctx, &q, nil); err != nil {
+				t.Error(err)
+			}
+		}(root)
+	}
+	wg.Wait()
+
+	// the span of the batched request is a child of the span of one of the callers
+	var httpSpans []string
+	for _, span := range tracer.Spans() {
+		if strings.HasSuffix(span, " > "+graphql.SpanHTTP) {
+			httpSpans = append(httpSpans, span)
+		}
+	}
+	if len(httpSpans) != 1 || (httpSpans[0] != "root1 > "+graphql.SpanHTTP && httpSpans[0] != "root2 > "+graphql.SpanHTTP) {
+		t.Errorf("got http spans: %q, want a child span of root1 or root2", httpSpans)
+	}
+}
+
+// messageConn is a WebsocketConn that reads the messages and records the written messages.
+type messageConn struct {
+	mu       sync.Mutex
+	messages []graphql.OperationMessage
+	written  []graphql.OperationMessage
+}
+
+func (c *messageConn) ReadJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.messages) == 0 {
+		return websocket.CloseError{Code: websocket.StatusNormalClosure}
+	}
+	*v.(*graphql.OperationMessage) = c.messages[0]
+	c.messages = c.messages[1:]
+	return nil
+}
+
+func (c *messageConn) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written = append(c.written, v.(graphql.OperationMessage))
+	return nil
+}
+
+func (c *messageConn) Close() error             { return nil }
+func (c *messageConn) SetReadLimit(limit int64) {}
+
+func TestSubscriptionClient_tracing(t *testing.T) {
+	conn := &messageConn{}
+	tracer := &recordingTracer{}
+	client := graphql.NewSubscriptionClient("ws://localhost/graphql").
+		WithConnectionParams(map[string]interface{}{
+			"headers": map[string]string{"Authorization": "Bearer token"},
+		}).
+		WithWebSocket(func(sc *graphql.SubscriptionClient) (graphql.WebsocketConn, error) {
+			return conn, nil
+		}).
+		WithTracer(tracer)
+
+	var sub struct {
+		User struct {
+			Name string
+		}
+	}
+	done := make(chan struct{})
+	id, err := client.Subscribe(&sub, nil, func(data *json.RawMessage, err error) error {
+		close(done)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.messages = []graphql.OperationMessage{
+		{ID: id, Type: graphql.GQL_DATA, Payload: json.RawMessage(`{"data": {"user": {"name": "Gopher"}}}`)},
+	}
+
+	if err := client.Run(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	if got, want := string(conn.written[0].Payload), `{"headers":{"Authorization":"Bearer token","traceparent":"graphql.subscription.connect"}}`; got != want {
+		t.Errorf("got connection_init payload: %s, want: %s", got, want)
+	}
+	want := []string{
+		" > " + graphql.SpanSubscriptionConnect,
+		" > " + graphql.SpanSubscriptionStart,
+		" > " + graphql.SpanSubscriptionData,
+	}
+	// the data span ends after the handler returns
+	deadline := time.Now().Add(time.Second)
+	for len(tracer.Spans()) < len(want) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := tracer.Spans(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got spans: %q, want: %q", got, want)
+	}
+}
@@ -0,0 +1,100 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// typeRegistry maps the __typename of objects to the Go types that interface fields of queries
+// are decoded into. It's copied on write, so that the selection sets cached for a registry stay valid.
+type typeRegistry struct {
+	types map[string]reflect.Type
+	// queries caches the selection sets built with the registry, like queryCache
+	queries sync.Map // map[reflect.Type]*builtQuery
+}
+
+// with returns a copy of r with the Go type t of the objects of typename.
+func (r *typeRegistry) with(typename string, t reflect.Type) *typeRegistry {
+	types := make(map[string]reflect.Type)
+	if r != nil {
+		for name, typ := range r.types {
+			types[name] = typ
+		}
+	}
+	types[typename] = t
+	return &typeRegistry{types: types}
+}
+
+// typesMap returns the Go types by __typename, or nil if r is nil.
+func (r *typeRegistry) typesMap() map[string]reflect.Type {
+	if r == nil {
+		return nil
+	}
+	return r.types
+}
+
+// implementations returns the sorted typenames whose Go types implement the interface t.
+// Interfaces without methods, like the ID type, have no implementations.
+func (r *typeRegistry) implementations(t reflect.Type) []string {
+	if r == nil || t.NumMethod() == 0 {
+		return nil
+	}
+	var typenames []string
+	for typename, typ := range r.types {
+		if typ.Implements(t) {
+			typenames = append(typenames, typename)
+		}
+	}
+	sort.Strings(typenames)
+	return typenames
+}
+
+// writeInterface writes the selection set of the interface t to b: __typename, and an inline fragment
+// or the named fragment spread of each registered Go type that implements t,
+// e.g. "{__typename,... on Droid{primaryFunction},... on Human{height}}".
+// Interfaces without implementations are scalars.
+func (b *queryBuilder) writeInterface(t reflect.Type, path string) error {
+	typenames := b.types.implementations(t)
+	if len(typenames) == 0 {
+		return nil
+	}
+	for _, it := range b.interfaces {
+		if it == t {
+			return queryFieldError(path, fmt.Errorf("the selection of interface %v is recursive, use a struct type for the nested selection", t))
+		}
+	}
+	b.interfaces = append(b.interfaces, t)
+	defer func() {
+		b.interfaces = b.interfaces[:len(b.interfaces)-1]
+	}()
+
+	b.buf.WriteString("{__typename")
+	for _, typename := range typenames {
+		typ := b.types.types[typename]
+		typePath := fmt.Sprintf("%s.(%v)", path, typ)
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return queryFieldError(typePath, fmt.Errorf("the Go type of __typename %s must be a struct or a pointer to a struct", typename))
+		}
+		b.buf.WriteString(",")
+		if name, typeCondition, ok := namedFragment(typ); ok {
+			if err := b.writeFragmentSpread(typ, reflect.Value{}, typePath, true, name, typeCondition); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isName(typename) {
+			return queryFieldError(typePath, fmt.Errorf("invalid __typename %q", typename))
+		}
+		b.buf.WriteString("... on " + typename)
+		if err := b.writeStruct(typ, reflect.Value{}, typePath, false); err != nil {
+			return err
+		}
+	}
+	b.buf.WriteString("}")
+	return nil
+}
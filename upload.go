@@ -0,0 +1,165 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// File uploads follow the GraphQL multipart request specification
+// https://github.com/jaydenseric/graphql-multipart-request-spec
+
+// Upload represents a file of the Upload scalar type. Variables with Upload values
+// are sent as a multipart/form-data request, and the file is streamed from File.
+type Upload struct {
+	// File is read when the request is sent.
+	File io.Reader
+	// Name is the file name.
+	Name string
+	// ContentType is the MIME type of the file. If empty, application/octet-stream is used.
+	ContentType string
+}
+
+// MarshalJSON encodes the upload as null in the operations part of the request.
+// The file is sent in a part of its own.
+func (u Upload) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+var uploadType = reflect.TypeOf(Upload{})
+
+// uploadFile is an upload and its object path in the operations part, e.g. "variables.files.0".
+type uploadFile struct {
+	path   string
+	upload *Upload
+}
+
+// findUploads returns the uploads of the variables, including uploads nested in lists and input objects.
+func findUploads(variables map[string]interface{}) []uploadFile {
+	var uploads []uploadFile
+	appendUploads(&uploads, "variables", reflect.ValueOf(variables))
+	return uploads
+}
+
+func appendUploads(uploads *[]uploadFile, path string, v reflect.Value) {
+	if !v.IsValid() {
+		return
+	}
+	if v.Type() == uploadType {
+		u := v.Interface().(Upload)
+		*uploads = append(*uploads, uploadFile{path: path, upload: &u})
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			appendUploads(uploads, path, v.Elem())
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		// sort the keys, so that the files are sent in a stable order
+		for _, key := range sortedMapKeys(v) {
+			appendUploads(uploads, path+"."+key.String(), v.MapIndex(key))
+		}
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		default:
+			// lists of scalars can't contain uploads
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			appendUploads(uploads, path+"."+strconv.Itoa(i), v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			switch {
+			case name == "-":
+				continue
+			case name == "" && f.Anonymous:
+				// fields of embedded structs are promoted
+				appendUploads(uploads, path, v.Field(i))
+				continue
+			case name == "":
+				name = f.Name
+			}
+			appendUploads(uploads, path+"."+name, v.Field(i))
+		}
+	}
+}
+
+// newMultipartRequest creates a multipart/form-data POST request with the operations, map and file parts.
+// The body is written by a goroutine while the request is sent, so the files aren't buffered in memory.
+func (c *Client) newMultipartRequest(in *requestPayload, uploads []uploadFile) (*http.Request, error) {
+	operations, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	fileMap := make(map[string][]string, len(uploads))
+	for i, u := range uploads {
+		fileMap[strconv.Itoa(i)] = []string{u.path}
+	}
+	mapPart, err := json.Marshal(fileMap)
+	if err != nil {
+		return nil, err
+	}
+
+	body, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	req, err := http.NewRequest(http.MethodPost, c.url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	go func() {
+		pw.CloseWithError(writeMultipart(mw, operations, mapPart, uploads))
+	}()
+	return req, nil
+}
+
+// writeMultipart writes the parts of a multipart request in the order of the specification.
+func writeMultipart(mw *multipart.Writer, operations []byte, mapPart []byte, uploads []uploadFile) error {
+	if err := mw.WriteField("operations", string(operations)); err != nil {
+		return err
+	}
+	if err := mw.WriteField("map", string(mapPart)); err != nil {
+		return err
+	}
+	for i, u := range uploads {
+		contentType := u.upload.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%d"; filename="%s"`, i, quoteEscaper.Replace(u.upload.Name)))
+		header.Set("Content-Type", contentType)
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if u.upload.File == nil {
+			continue
+		}
+		if _, err := io.Copy(part, u.upload.File); err != nil {
+			return fmt.Errorf("failed to read upload %s: %w", u.path, err)
+		}
+	}
+	return mw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
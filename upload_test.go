@@ -0,0 +1,74 @@
+package graphql_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+func TestClient_Mutate_upload(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Error(err)
+			return
+		}
+		if got, want := req.FormValue("operations"), `{"query":"mutation ($files:[Upload!]!$input:UploadInput!){upload(files: $files, input: $input){id}}","variables":{"files":[null,null],"input":{"name":"gopher","avatar":null}}}`; got != want {
+			t.Errorf("got operations: %s, want: %s", got, want)
+		}
+		if got, want := req.FormValue("map"), `{"0":["variables.files.0"],"1":["variables.files.1"],"2":["variables.input.avatar"]}`; got != want {
+			t.Errorf("got map: %s, want: %s", got, want)
+		}
+		for _, want := range []struct {
+			field, filename, contentType, content string
+		}{
+			{"0", "a.txt", "text/plain", "first file"},
+			{"1", "b.bin", "application/octet-stream", "second file"},
+			{"2", "avatar.png", "image/png", "avatar"},
+		} {
+			file, header, err := req.FormFile(want.field)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			content, _ := ioutil.ReadAll(file)
+			if header.Filename != want.filename || header.Header.Get("Content-Type") != want.contentType || string(content) != want.content {
+				t.Errorf("got file %s: %s %s %q, want: %s %s %q", want.field, header.Filename, header.Header.Get("Content-Type"), content, want.filename, want.contentType, want.content)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		mustWrite(w, `{"data": {"upload": {"id": "1"}}}`)
+	})
+	client := graphql.NewClient("/graphql", &http.Client{Transport: localRoundTripper{handler: mux}})
+
+	type UploadInput struct {
+		Name   string         `json:"name"`
+		Avatar graphql.Upload `json:"avatar"`
+	}
+	var m struct {
+		Upload struct {
+			ID graphql.ID
+		} `graphql:"upload(files: $files, input: $input)"`
+	}
+	variables := map[string]interface{}{
+		"files": []graphql.Upload{
+			{File: strings.NewReader("first file"), Name: "a.txt", ContentType: "text/plain"},
+			{File: strings.NewReader("second file"), Name: "b.bin"},
+		},
+		"input": UploadInput{
+			Name:   "gopher",
+			Avatar: graphql.Upload{File: strings.NewReader("avatar"), Name: "avatar.png", ContentType: "image/png"},
+		},
+	}
+	err := client.Mutate(context.Background(), &m, variables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Upload.ID, "1"; got != want {
+		t.Errorf("got m.Upload.ID: %q, want: %q", got, want)
+	}
+}